@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// alternate address (normalized) => roster email
+var aliases map[string]string
+
+const aliasesFile = "config/aliases.json"
+
+// normalizeEmail maps the different spellings of an address to one form: it is lowercased, a "+tag" suffix is dropped
+// from the local part, and since Gmail ignores dots, they are dropped from Gmail addresses.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus != -1 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+func loadAliases() {
+	aliases = make(map[string]string)
+	aliasesBytes, err := os.ReadFile(aliasesFile)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+	var fileAliases map[string]string
+	err = json.Unmarshal(aliasesBytes, &fileAliases)
+	if err != nil {
+		panic(err)
+	}
+	for alias, email := range fileAliases {
+		aliases[normalizeEmail(alias)] = email
+	}
+}
+
+func saveAlias(alias string, email string) {
+	fileAliases := make(map[string]string)
+	if aliasesBytes, err := os.ReadFile(aliasesFile); err == nil {
+		err = json.Unmarshal(aliasesBytes, &fileAliases)
+		if err != nil {
+			panic(err)
+		}
+	}
+	fileAliases[strings.ToLower(strings.TrimSpace(alias))] = email
+	aliases[normalizeEmail(alias)] = email
+
+	aliasesBytes, err := json.MarshalIndent(fileAliases, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(aliasesFile, aliasesBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// offerAlias asks the officer which roster entry an unknown address belongs to, and remembers the answer in
+// config/aliases.json. It returns nil if the officer skips it.
+func offerAlias(email string) *Member {
+	for {
+		fmt.Print("`" + email + "' isn't in the roster. Enter the roster email address it belongs to, or press [Enter] to skip: ")
+		var rosterEmail string
+		fmt.Scanln(&rosterEmail)
+		if rosterEmail == "" {
+			audit("skipped unknown address " + email)
+			return nil
+		}
+		member := findMember(rosterEmail)
+		if member == nil {
+			fmt.Println("`" + rosterEmail + "' isn't in the roster either.")
+			continue
+		}
+		saveAlias(email, member.Email)
+		audit("added alias " + email + " for " + member.Email)
+		return member
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"a@example.org", "a@example.org"},
+		{"  A@Example.ORG ", "a@example.org"},
+		{"a+election@example.org", "a@example.org"},
+		{"a.b@example.org", "a.b@example.org"},
+		{"A.B+x@gmail.com", "ab@gmail.com"},
+		{"a.b@googlemail.com", "ab@gmail.com"},
+		{"not an address", "not an address"},
+	}
+	for _, test := range tests {
+		if got := normalizeEmail(test.email); got != test.want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// With ballot_mode "anonymous", opening a voting link records the voter on the roll and hands them an anonymous
+// credential, which is what their ballot is cast with. Only a hash of the credential is stored, along with what the
+// ballot may contain until it is cast. Cast ballots only keep their nonzero scores; voters' weights and how many ballots
+// could vote for each position are only kept as running totals, so a ballot doesn't give away who cast it through its
+// weight or the positions it could vote for. A single copy of the state files taken after voting doesn't say whose ballot
+// is whose, but this is not a cryptographic guarantee: the server sees the voting link and the credential in the same
+// request, and each vote changes the roll, the credentials and the ballots, so whoever runs the server or compares
+// copies of state/ taken during the vote can tell which voter cast which ballot.
+
+// sorted emails of voters that have been issued a credential
+const rollFile = "state/roll.json"
+
+// credential hash => credential
+const credentialsFile = "state/credentials.json"
+
+const anonymousTotalsFile = "state/anonymous_totals.json"
+
+// anonymousTotals adds up what anonymous ballots don't store individually.
+type anonymousTotals struct {
+	// position => candidate => sum of weight * score
+	Scores map[string]map[string]float64 `json:"scores"`
+	// position => number of ballots cast that could vote for it
+	PositionVotes map[string]uint `json:"position_votes"`
+}
+
+type anonymousCredential struct {
+	// positions the ballot may score
+	Positions []string `json:"positions"`
+	Weight    float64  `json:"weight"`
+	Used      bool     `json:"used"`
+}
+
+func hashCredential(credential string) string {
+	hash := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(hash[:])
+}
+
+// issueCredential spends a voting link and returns a fresh anonymous credential for its voter. The caller must hold
+// lockBallots and save the tokens.
+func issueCredential(t *ballotToken) string {
+	member := findMember(t.Email)
+	c := &anonymousCredential{Positions: []string{}, Weight: member.weight()}
+	for _, position := range electionConfig.Positions {
+		if canVoteFor(findPosition(position.Name), member) {
+			c.Positions = append(c.Positions, position.Name)
+		}
+	}
+
+	credential := randomToken()
+	credentials := make(map[string]*anonymousCredential)
+	readJSON(credentialsFile, &credentials)
+	credentials[hashCredential(credential)] = c
+	writeJSON(credentialsFile, credentials)
+
+	// kept sorted, so the order voters show up in doesn't match the order of the ballots
+	var roll []string
+	readJSON(rollFile, &roll)
+	roll = append(roll, t.Email)
+	sort.Strings(roll)
+	writeJSON(rollFile, roll)
+
+	t.Used = true
+	return credential
+}
+
+func handleCast(w http.ResponseWriter, req *http.Request, candidates []positionCandidates) {
+	page := ballotPage{Name: electionConfig.Name, Description: electionConfig.VoteDescription, Scores: []int{0, 1, 2}}
+	render := func(status int, message string) {
+		page.Message = message
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		ballotTemplate.Execute(w, page)
+	}
+
+	defer lockBallots()()
+
+	credentials := make(map[string]*anonymousCredential)
+	readJSON(credentialsFile, &credentials)
+	c, ok := credentials[hashCredential(mux.Vars(req)["credential"])]
+	if !ok {
+		render(http.StatusNotFound, "This ballot link isn't valid.")
+		return
+	}
+	if c.Used {
+		render(http.StatusGone, "This ballot has already been cast.")
+		return
+	}
+	if votingClosed() {
+		render(http.StatusForbidden, "Voting has closed.")
+		return
+	}
+
+	page.Positions = ballotPositions(candidates, func(position string) bool {
+		for _, allowed := range c.Positions {
+			if allowed == position {
+				return true
+			}
+		}
+		return false
+	})
+
+	if req.Method != "POST" {
+		render(http.StatusOK, "")
+		return
+	}
+
+	scores, ok := parseBallot(req, page.Positions)
+	if !ok {
+		render(http.StatusBadRequest, "Your ballot couldn't be read. Please try again.")
+		return
+	}
+	totals := anonymousTotals{}
+	readJSON(anonymousTotalsFile, &totals)
+	for _, position := range c.Positions {
+		totals.PositionVotes[position] += 1
+	}
+	// only nonzero scores are kept, so a ballot doesn't list the positions its voter could vote for
+	nonzero := make(map[string]map[string]uint)
+	for position, candidates := range scores {
+		for candidate, score := range candidates {
+			if score == 0 {
+				continue
+			}
+			if nonzero[position] == nil {
+				nonzero[position] = make(map[string]uint)
+				if totals.Scores[position] == nil {
+					totals.Scores[position] = make(map[string]float64)
+				}
+			}
+			nonzero[position][candidate] = score
+			totals.Scores[position][candidate] += c.Weight * float64(score)
+		}
+	}
+	ballot := localBallot{Scores: nonzero, Nonce: randomToken()}
+
+	// insert at a random position, so the order of the ballots doesn't give away when each was cast
+	var ballots []localBallot
+	readJSON(ballotsFile, &ballots)
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(ballots)+1)))
+	if err != nil {
+		panic(err)
+	}
+	i := int(idx.Int64())
+	ballots = append(ballots, localBallot{})
+	copy(ballots[i+1:], ballots[i:])
+	ballots[i] = ballot
+	writeJSON(ballotsFile, ballots)
+
+	writeJSON(anonymousTotalsFile, totals)
+
+	// a used credential doesn't need to say what its ballot could contain
+	c.Used, c.Positions, c.Weight = true, nil, 0
+	writeJSON(credentialsFile, credentials)
+
+	if ballotsIdentifiable() {
+		render(http.StatusOK, "Thanks for voting! Your ballot has been recorded without your name.")
+		return
+	}
+	render(http.StatusOK, "Thanks for voting! Your ballot has been recorded without your name. Your receipt is "+ballotReceipt(ballot.Nonce, 1, nonzero)+receiptNote)
+}
+
+// readAnonymousTotals reads the running totals of the anonymous ballots.
+func readAnonymousTotals() *anonymousTotals {
+	defer lockBallots()()
+
+	totals := &anonymousTotals{}
+	readJSON(anonymousTotalsFile, totals)
+	return totals
+}
+
+// readRoll returns the members that have been issued a credential.
+func readRoll() map[*Member]bool {
+	defer lockBallots()()
+
+	var roll []string
+	readJSON(rollFile, &roll)
+	voted := make(map[*Member]bool)
+	for _, email := range roll {
+		if member := findMember(email); member != nil {
+			voted[member] = true
+		}
+	}
+	return voted
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// archiveName is the folder under archive/ that an election is moved to, e.g. "2022-board-election".
+func archiveName() string {
+	slug := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(electionConfig.Name))
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return fmt.Sprint(time.Now().Year()) + "-" + strings.Trim(slug, "-")
+}
+
+func copyFile(src string, dst string) {
+	in, err := os.Open(src)
+	if err != nil {
+		panic(err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// copyRedactedElectionFile copies election.toml without the secrets in its [discord] table. The copy is re-encoded, so
+// comments and formatting aren't kept.
+func copyRedactedElectionFile(dst string) {
+	var election map[string]interface{}
+	_, err := toml.DecodeFile(electionFile, &election)
+	if err != nil {
+		panic(err)
+	}
+	if discord, ok := election["discord"].(map[string]interface{}); ok {
+		delete(discord, "webhook")
+		delete(discord, "bot_token")
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+	err = toml.NewEncoder(out).Encode(election)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// handleArchive moves the state folder into the archive along with a copy of the config, so that a new election can be
+// started without losing the record of this one.
+func handleArchive() {
+	if _, err := os.Stat("state"); err != nil {
+		fmt.Println("There is no `state' folder, so there is nothing to archive.")
+		os.Exit(1)
+	}
+	auditAction = "archive"
+	audit("started")
+	if _, err := os.Stat("state/results.txt"); err != nil {
+		fmt.Println("`state/results.txt' does not exist, meaning the results haven't been sent out yet.")
+		confirm("Press [Enter] to archive the election anyways, or [Ctrl-C] to cancel: ")
+	}
+
+	dir := filepath.Join("archive", archiveName())
+	if _, err := os.Stat(dir); err == nil {
+		fmt.Println("`" + dir + "' already exists! Rename it, or change the election name, and try again.")
+		os.Exit(1)
+	}
+	err := os.MkdirAll(filepath.Join(dir, "config"), 0700)
+	if err != nil {
+		panic(err)
+	}
+
+	// discord.json and the [discord] table of election.toml hold the webhook URL and bot token, which shouldn't be kept
+	// around
+	configFiles, err := os.ReadDir("config")
+	if err != nil {
+		panic(err)
+	}
+	for _, configFile := range configFiles {
+		if configFile.IsDir() || configFile.Name() == "discord.json" {
+			continue
+		}
+		if filepath.Join("config", configFile.Name()) == filepath.FromSlash(electionFile) {
+			copyRedactedElectionFile(filepath.Join(dir, "config", configFile.Name()))
+			continue
+		}
+		copyFile(filepath.Join("config", configFile.Name()), filepath.Join(dir, "config", configFile.Name()))
+	}
+
+	// the last entry in the election's log
+	audit("archived to " + dir)
+	err = os.Rename("state", filepath.Join(dir, "state"))
+	if err != nil {
+		panic(err)
+	}
+
+	// link the election's winners to its archive
+	history := loadHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Name == electionConfig.Name && history[i].Archive == "" {
+			history[i].Archive = dir
+			saveHistory(history)
+			break
+		}
+	}
+
+	fmt.Println("Archived the " + electionConfig.Name + " to `" + dir + "'. You can now start a new election.")
+	os.Exit(0)
+}
+
+// handleHistory lists past elections and their winners.
+func handleHistory() {
+	history := loadHistory()
+	if len(history) == 0 {
+		fmt.Println("No elections have been recorded yet.")
+		os.Exit(0)
+	}
+	for _, election := range history {
+		fmt.Print(election.Date + " " + election.Name)
+		if election.Archive != "" {
+			fmt.Print(" (" + election.Archive + ")")
+		}
+		fmt.Println()
+
+		// list positions in config order, then any that have been removed since
+		listed := make(map[string]bool)
+		for _, position := range electionConfig.Positions {
+			if winner, ok := election.Winners[position.Name]; ok {
+				fmt.Println("\t- " + position.Name + ": " + winner.Name)
+				listed[position.Name] = true
+			}
+		}
+		for position, winner := range election.Winners {
+			if !listed[position] {
+				fmt.Println("\t- " + position + ": " + winner.Name)
+			}
+		}
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions that change the election append entries to state/audit.log, one JSON object per line. Each entry includes the
+// hash of the entry before it, so editing, inserting or removing an entry breaks the chain, which `verify-log` checks.
+
+const auditFile = "state/audit.log"
+
+type auditEntry struct {
+	Time    string `json:"time"`
+	Officer string `json:"officer"`
+	Action  string `json:"action"`
+	Event   string `json:"event"`
+	// hash of the files in the config folder when the entry was written
+	ConfigHash string `json:"config_hash"`
+	Prev       string `json:"prev"`
+	Hash       string `json:"hash"`
+}
+
+// the action being logged, empty for actions that aren't logged
+var auditAction string
+
+// guards appending to the log within this process; auditLockFile guards it between processes, e.g. the bot and the
+// actions it runs
+var auditMutex sync.Mutex
+
+const auditLockFile = "state/audit.lock"
+
+// hash of the entry with Hash left empty
+func (e auditEntry) computeHash() string {
+	e.Hash = ""
+	entryBytes, err := json.Marshal(e)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(entryBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// officerName is ELECTION_OFFICER if set (the bot sets it to the Discord user that ran the action), otherwise the name
+// of the OS user.
+func officerName() string {
+	if officer := os.Getenv("ELECTION_OFFICER"); officer != "" {
+		return officer
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// configHash hashes the name and contents of every file in the config folder.
+func configHash() string {
+	paths := []string{}
+	filepath.WalkDir("config", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(path), len(contents))
+		hash.Write(contents)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func readAuditLog() []auditEntry {
+	f, err := os.Open(auditFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry auditEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			fmt.Println("Line " + fmt.Sprint(line) + " of `" + auditFile + "' isn't a valid entry: " + err.Error())
+			os.Exit(1)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return entries
+}
+
+// auditHead is the hash of the latest entry, which commits to the whole log up to it.
+func auditHead() string {
+	entries := readAuditLog()
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[len(entries)-1].Hash
+}
+
+// entries made before there was a state folder to log them in
+var pendingAudit []auditEntry
+
+// audit appends an entry to the audit log. Until the state folder exists, entries are held back, so that an action run
+// by mistake before the election has started doesn't leave behind a state folder that looks like an election.
+func audit(event string) {
+	if auditAction == "" {
+		return
+	}
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	pendingAudit = append(pendingAudit, auditEntry{
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		Officer:    officerName(),
+		Action:     auditAction,
+		Event:      event,
+		ConfigHash: configHash(),
+	})
+	if _, err := os.Stat("state"); err != nil {
+		return
+	}
+	// the previous entry has to be read and the new one appended without another process appending in between
+	defer lockFile(auditLockFile)()
+
+	f, err := os.OpenFile(auditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	for _, entry := range pendingAudit {
+		entry.Prev = auditHead()
+		entry.Hash = entry.computeHash()
+		entryBytes, err := json.Marshal(entry)
+		if err != nil {
+			panic(err)
+		}
+		_, err = f.Write(append(entryBytes, '\n'))
+		if err != nil {
+			panic(err)
+		}
+	}
+	pendingAudit = nil
+}
+
+// confirm waits for the officer to press [Enter], and records that they did.
+func confirm(prompt string) {
+	fmt.Print(prompt)
+	fmt.Scanln()
+	fmt.Println()
+	audit("confirmed: " + strings.TrimSpace(prompt))
+}
+
+// handleVerifyLog checks the hash chain, and that knownHash (e.g. the one posted with the results) is still in it.
+func handleVerifyLog(knownHash string) {
+	entries := readAuditLog()
+	if entries == nil {
+		fmt.Println("`" + auditFile + "' does not exist, so there is nothing to verify.")
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("`" + auditFile + "' is empty: its entries have been removed.")
+		os.Exit(1)
+	}
+
+	prev := ""
+	foundKnownHash := false
+	for i, entry := range entries {
+		if entry.Prev != prev {
+			fmt.Println("Entry " + fmt.Sprint(i+1) + " of `" + auditFile + "' doesn't follow the entry before it: an entry has been removed, inserted or reordered.")
+			os.Exit(1)
+		}
+		if entry.computeHash() != entry.Hash {
+			fmt.Println("Entry " + fmt.Sprint(i+1) + " of `" + auditFile + "' (" + entry.Time + ", " + entry.Action + ") has been modified.")
+			os.Exit(1)
+		}
+		if entry.Hash == knownHash {
+			foundKnownHash = true
+		}
+		prev = entry.Hash
+	}
+	if knownHash != "" && !foundKnownHash {
+		fmt.Println("No entry of `" + auditFile + "' has the hash " + knownHash + ": entries have been removed from the end, or the log was rewritten.")
+		os.Exit(1)
+	}
+
+	fmt.Println("`" + auditFile + "' is intact: " + fmt.Sprint(len(entries)) + " entries, the latest with hash " + prev + ".")
+	if knownHash == "" {
+		fmt.Println("To check that no entries were removed from the end, pass the hash posted with the results to `verify-log'.")
+	}
+	os.Exit(0)
+}
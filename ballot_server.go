@@ -0,0 +1,420 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/forms/v1"
+)
+
+// stored in state/ballot.txt instead of a form ID when voting happens on the built-in ballot server
+const localBallotID = "local"
+
+const tokensFile = "state/tokens.json"
+const ballotsFile = "state/ballots.json"
+
+type ballotToken struct {
+	Email string `json:"email"`
+	Used  bool   `json:"used"`
+}
+
+type localBallot struct {
+	// empty for anonymous ballots
+	Email       string `json:"email,omitempty"`
+	SubmittedAt string `json:"submitted_at,omitempty"`
+	// position => candidate => score
+	Scores map[string]map[string]uint `json:"scores"`
+	// what the voter's receipt was made with, see ballotReceipt
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// written by end-vote, so that no ballots are cast after the votes have been counted
+const ballotClosedFile = "state/ballot_closed.txt"
+
+// guards the state files written by the ballot server
+var ballotMutex sync.Mutex
+
+// lockBallots keeps other requests, and other processes like end-vote, from reading or changing the ballot server's
+// state files until the returned function is called.
+func lockBallots() func() {
+	ballotMutex.Lock()
+	unlock := lockFile("state/ballots.lock")
+	return func() {
+		unlock()
+		ballotMutex.Unlock()
+	}
+}
+
+// votingClosed reports whether the ballot server should stop taking ballots. The caller must hold lockBallots.
+func votingClosed() bool {
+	if _, err := os.Stat(ballotClosedFile); err == nil {
+		return true
+	}
+	return !voteDeadline.IsZero() && time.Now().After(voteDeadline)
+}
+
+// closeLocalBallot stops the ballot server from taking any more ballots.
+func closeLocalBallot() {
+	defer lockBallots()()
+	if _, err := os.Stat(ballotClosedFile); err == nil {
+		return
+	}
+	err := os.WriteFile(ballotClosedFile, []byte(time.Now().UTC().Format(time.RFC3339)), 0600)
+	if err != nil {
+		panic(err)
+	}
+	audit("closed the ballot server")
+}
+
+func usingLocalBallot() bool {
+	return electionConfig.BallotMode == "local" || usingAnonymousBallot()
+}
+
+func usingAnonymousBallot() bool {
+	return electionConfig.BallotMode == "anonymous"
+}
+
+func ballotAddr() string {
+	if electionConfig.BallotAddr != "" {
+		return electionConfig.BallotAddr
+	}
+	return "localhost:4446"
+}
+
+func ballotURL() string {
+	if electionConfig.BallotURL != "" {
+		return strings.TrimSuffix(electionConfig.BallotURL, "/")
+	}
+	return "http://" + ballotAddr()
+}
+
+func readJSON(path string, v interface{}) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func writeJSON(path string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	// write then rename, so a crash can't leave a half-written file
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		panic(err)
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func randomToken() string {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(token)
+}
+
+// openLocalBallot issues a one-time voting link to every eligible voter. Links are sent over Discord to members with a
+// known Discord ID; the rest are written to state/ballot_links.csv for the officer to send out. The links only go out
+// once the officer has started the ballot server.
+func openLocalBallot() {
+	tokens := make(map[string]*ballotToken)
+	// {email, link} tuples
+	links := [][2]string{}
+	for _, member := range roster {
+		if !member.Vote || member.Email == "" {
+			continue
+		}
+		token := randomToken()
+		tokens[token] = &ballotToken{Email: member.Email}
+		links = append(links, [2]string{member.Email, ballotURL() + "/ballot/" + token})
+	}
+	writeJSON(tokensFile, tokens)
+	writeJSON(ballotsFile, []localBallot{})
+	if usingAnonymousBallot() {
+		writeJSON(rollFile, []string{})
+		writeJSON(credentialsFile, map[string]*anonymousCredential{})
+		writeJSON(anonymousTotalsFile, anonymousTotals{Scores: make(map[string]map[string]float64), PositionVotes: make(map[string]uint)})
+	}
+	// serve-ballot needs this to start
+	err := os.WriteFile("state/ballot.txt", []byte(localBallotID), 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Start the ballot server with the `serve-ballot' action, and make sure it can be reached at " + ballotURL() + ".")
+	confirm("Press [Enter] when it is running to send out the voting links: ")
+
+	undelivered := [][2]string{}
+	for _, tuple := range links {
+		member, link := findMember(tuple[0]), tuple[1]
+		if member.DiscordID != 0 && discordConfig.BotToken != "" {
+			err := sendDirectMessage(member.DiscordID, "Here is your personal link to vote in the "+electionConfig.Name+": "+link+"\nDon't share it with anyone; it can only be used once.")
+			if err == nil {
+				continue
+			}
+			fmt.Println("Couldn't DM " + member.Email + " their voting link: " + err.Error())
+		}
+		undelivered = append(undelivered, tuple)
+	}
+	audit(fmt.Sprintf("issued %d voting links", len(tokens)))
+
+	if len(undelivered) != 0 {
+		lines := []string{"email,link"}
+		for _, tuple := range undelivered {
+			lines = append(lines, tuple[0]+","+tuple[1])
+		}
+		err := os.WriteFile("state/ballot_links.csv", []byte(strings.Join(lines, "\n")+"\n"), 0600)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(fmt.Sprint(len(undelivered)) + " voters couldn't be sent their link over Discord. Email each of them their link from `state/ballot_links.csv', then delete it.")
+		confirm("Press [Enter] when you are done: ")
+	}
+}
+
+// loadLocalResponses reads the ballots cast on the ballot server in the same shape as Forms responses, so that they can
+// be counted the same way. It returns question ID => {position, candidate}, the responses, and the stored ballots by
+// response ID. Anonymous ballots have no respondent email.
+func loadLocalResponses() (map[string][2]string, []*forms.FormResponse, map[string]*localBallot) {
+	defer lockBallots()()
+
+	var ballots []*localBallot
+	readJSON(ballotsFile, &ballots)
+
+	questionIDs := make(map[string][2]string)
+	responses := []*forms.FormResponse{}
+	ballotsByID := make(map[string]*localBallot)
+	for i, ballot := range ballots {
+		resp := &forms.FormResponse{
+			ResponseId:        fmt.Sprint(i),
+			RespondentEmail:   ballot.Email,
+			LastSubmittedTime: ballot.SubmittedAt,
+			Answers:           make(map[string]forms.Answer),
+		}
+		ballotsByID[resp.ResponseId] = ballot
+		for position, scores := range ballot.Scores {
+			for candidate, score := range scores {
+				questionID := position + "\n" + candidate
+				questionIDs[questionID] = [2]string{position, candidate}
+				resp.Answers[questionID] = forms.Answer{
+					QuestionId:  questionID,
+					TextAnswers: &forms.TextAnswers{Answers: []*forms.TextAnswer{{Value: fmt.Sprint(score)}}},
+				}
+			}
+		}
+		responses = append(responses, resp)
+	}
+	return questionIDs, responses, ballotsByID
+}
+
+var ballotTemplate = template.Must(template.New("ballot").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}} Ballot</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.25em 1em; }
+p { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Name}} Ballot</h1>
+{{if .Message}}<p><strong>{{.Message}}</strong></p>{{else if .Continue}}
+<p>{{.Description}}</p>
+<form method="post"><button type="submit">Continue to your ballot</button></form>
+{{else}}
+<p>{{.Description}}</p>
+<form method="post">
+{{range $pi, $p := .Positions}}
+<h2>{{$p.Name}}</h2>
+<p>{{$p.Description}}</p>
+<table>
+<tr><th></th><th>0</th><th>1</th><th>2</th></tr>
+{{range $ci, $c := $p.Candidates}}<tr><td>{{$c}}</td>{{range $s := $.Scores}}<td><input type="radio" name="{{$pi}}/{{$ci}}" value="{{$s}}"></td>{{end}}</tr>
+{{end}}
+</table>
+{{end}}
+<p>Blank rows are treated like a 0. Your ballot can only be submitted once.</p>
+<button type="submit">Submit ballot</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type ballotPagePosition struct {
+	Name        string
+	Description string
+	Candidates  []string
+}
+
+type ballotPage struct {
+	Name        string
+	Description string
+	Message     string
+	// only shows a button that leads to the ballot
+	Continue  bool
+	Positions []ballotPagePosition
+	Scores    []int
+}
+
+// ballotPositions lists the positions on a voter's ballot.
+func ballotPositions(candidates []positionCandidates, allowed func(position string) bool) []ballotPagePosition {
+	positions := []ballotPagePosition{}
+	for _, position := range candidates {
+		if !allowed(position.Position) {
+			continue
+		}
+		description := ""
+		if p := findPosition(position.Position); p != nil {
+			description = p.Description
+		}
+		positions = append(positions, ballotPagePosition{Name: position.Position, Description: description, Candidates: position.Candidates})
+	}
+	return positions
+}
+
+// parseBallot reads submitted scores, whose fields are named "<position index>/<candidate index>".
+func parseBallot(req *http.Request, positions []ballotPagePosition) (map[string]map[string]uint, bool) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, false
+	}
+	scores := make(map[string]map[string]uint)
+	for field, values := range req.PostForm {
+		var positionIdx, candidateIdx int
+		_, err := fmt.Sscanf(field, "%d/%d", &positionIdx, &candidateIdx)
+		if err != nil || positionIdx < 0 || positionIdx >= len(positions) || candidateIdx < 0 || candidateIdx >= len(positions[positionIdx].Candidates) || len(values) != 1 {
+			return nil, false
+		}
+		score, err := strconv.ParseUint(values[0], 10, 32)
+		if err != nil || score > 2 {
+			return nil, false
+		}
+		position := positions[positionIdx].Name
+		if scores[position] == nil {
+			scores[position] = make(map[string]uint)
+		}
+		scores[position][positions[positionIdx].Candidates[candidateIdx]] = uint(score)
+	}
+	return scores, true
+}
+
+func handleServeBallot() {
+	ballotIDBytes, err := os.ReadFile("state/ballot.txt")
+	if err != nil || string(ballotIDBytes) != localBallotID {
+		fmt.Println("Voting on the ballot server hasn't started! Set `ballot_mode' to \"local\" or \"anonymous\" and use the `start-vote' command to open the ballot.")
+		os.Exit(1)
+	}
+
+	var candidates []positionCandidates
+	readJSON("state/candidates.json", &candidates)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/ballot/{token}", func(w http.ResponseWriter, req *http.Request) {
+		token := mux.Vars(req)["token"]
+		page := ballotPage{Name: electionConfig.Name, Description: electionConfig.VoteDescription, Scores: []int{0, 1, 2}}
+		render := func(status int, message string) {
+			page.Message = message
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			ballotTemplate.Execute(w, page)
+		}
+
+		defer lockBallots()()
+
+		tokens := make(map[string]*ballotToken)
+		readJSON(tokensFile, &tokens)
+		t, ok := tokens[token]
+		if !ok {
+			render(http.StatusNotFound, "This voting link isn't valid.")
+			return
+		}
+		if t.Used {
+			render(http.StatusGone, "This voting link has already been used.")
+			return
+		}
+		if votingClosed() {
+			render(http.StatusForbidden, "Voting has closed.")
+			return
+		}
+
+		if usingAnonymousBallot() {
+			// link previews load the page too, so the link is only spent once the voter presses the button
+			if req.Method != "POST" {
+				page.Continue = true
+				page.Description = "Your ballot is stored without your name. Once you continue, your ballot gets a new link that isn't recorded with your name, and this link stops working, so keep the next page open until you have voted."
+				render(http.StatusOK, "")
+				return
+			}
+			// trade the voter's link for an anonymous credential, so the stored ballot isn't filed under the voter
+			credential := issueCredential(t)
+			writeJSON(tokensFile, tokens)
+			http.Redirect(w, req, "/cast/"+credential, http.StatusSeeOther)
+			return
+		}
+
+		// only the positions the voter may vote for are on their ballot
+		member := findMember(t.Email)
+		page.Positions = ballotPositions(candidates, func(position string) bool {
+			return canVoteFor(findPosition(position), member)
+		})
+
+		if req.Method != "POST" {
+			render(http.StatusOK, "")
+			return
+		}
+
+		scores, ok := parseBallot(req, page.Positions)
+		if !ok {
+			render(http.StatusBadRequest, "Your ballot couldn't be read. Please try again.")
+			return
+		}
+		ballot := localBallot{Email: t.Email, SubmittedAt: time.Now().UTC().Format(time.RFC3339Nano), Scores: scores, Nonce: randomToken()}
+
+		var ballots []localBallot
+		readJSON(ballotsFile, &ballots)
+		writeJSON(ballotsFile, append(ballots, ballot))
+		t.Used = true
+		writeJSON(tokensFile, tokens)
+
+		if ballotsIdentifiable() {
+			render(http.StatusOK, "Thanks for voting! Your ballot has been recorded.")
+			return
+		}
+		render(http.StatusOK, "Thanks for voting! Your ballot has been recorded. Your receipt is "+ballotReceipt(ballot.Nonce, member.weight(), scores)+receiptNote)
+	}).Methods("GET", "POST")
+
+	if usingAnonymousBallot() {
+		r.HandleFunc("/cast/{credential}", func(w http.ResponseWriter, req *http.Request) {
+			handleCast(w, req, candidates)
+		}).Methods("GET", "POST")
+	}
+
+	fmt.Println("Serving the ballot on http://" + ballotAddr() + "; voters' links start with " + ballotURL() + "/ballot/")
+	panic(http.ListenAndServe(ballotAddr(), r))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type guildMember struct {
+	User  discordUser `json:"user"`
+	Roles []string    `json:"roles"`
+}
+
+type roleChange struct {
+	userID string
+	roleID string
+	add    bool
+	// shown to the officer
+	description string
+}
+
+// memberID looks up the Discord user ID of a member by name or email, in the roster and then in member_ids.
+func memberID(nameOrEmail string) (string, bool) {
+	if member := findMemberByName(nameOrEmail); member != nil && member.DiscordID != 0 {
+		return strconv.FormatUint(member.DiscordID, 10), true
+	}
+	if member := findMember(nameOrEmail); member != nil && member.DiscordID != 0 {
+		return strconv.FormatUint(member.DiscordID, 10), true
+	}
+	for key, id := range discordConfig.MemberIDs {
+		if strings.EqualFold(strings.TrimSpace(key), strings.TrimSpace(nameOrEmail)) {
+			return strconv.FormatUint(id, 10), true
+		}
+	}
+	return "", false
+}
+
+// knowDiscordIDs reports whether any member's Discord ID is configured.
+func knowDiscordIDs() bool {
+	if len(discordConfig.MemberIDs) != 0 {
+		return true
+	}
+	for _, member := range roster {
+		if member.DiscordID != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateBoardRoles moves the board role (and position roles) from the outgoing board to the winners, after showing the
+// officer what will change.
+func updateBoardRoles(winners map[string]string) {
+	guildPath := "/guilds/" + fmt.Sprint(discordConfig.GuildID)
+	boardRole := fmt.Sprint(discordConfig.BoardID)
+
+	// Discord returns at most 1000 members at a time, ordered by user ID
+	var members []guildMember
+	after := "0"
+	for {
+		var page []guildMember
+		err := json.Unmarshal(discordRequest("GET", guildPath+"/members?limit=1000&after="+after, nil), &page)
+		if err != nil {
+			panic(err)
+		}
+		members = append(members, page...)
+		if len(page) < 1000 {
+			break
+		}
+		after = page[len(page)-1].User.ID
+	}
+	membersByID := make(map[string]guildMember)
+	for _, member := range members {
+		membersByID[member.User.ID] = member
+	}
+	hasRole := func(userID string, roleID string) bool {
+		for _, role := range membersByID[userID].Roles {
+			if role == roleID {
+				return true
+			}
+		}
+		return false
+	}
+
+	// user ID => role IDs they should have
+	wanted := make(map[string]map[string]bool)
+	unknown := []string{}
+	for _, position := range electionConfig.Positions {
+		winner, ok := winners[position.Name]
+		if !ok {
+			continue
+		}
+		userID, ok := memberID(winner)
+		if !ok {
+			unknown = append(unknown, winner)
+			continue
+		}
+		wanted[userID] = map[string]bool{boardRole: true}
+		if roleID, ok := discordConfig.PositionRoleIDs[position.Name]; ok {
+			wanted[userID][fmt.Sprint(roleID)] = true
+		}
+	}
+
+	managedRoles := []string{boardRole}
+	for _, roleID := range discordConfig.PositionRoleIDs {
+		managedRoles = append(managedRoles, fmt.Sprint(roleID))
+	}
+	roleName := func(roleID string) string {
+		if roleID == boardRole {
+			return "board"
+		}
+		for position, id := range discordConfig.PositionRoleIDs {
+			if fmt.Sprint(id) == roleID {
+				return position
+			}
+		}
+		return roleID
+	}
+	memberName := func(userID string) string {
+		if member, ok := membersByID[userID]; ok {
+			return member.User.Username + " (" + userID + ")"
+		}
+		return userID
+	}
+
+	changes := []roleChange{}
+	for _, member := range members {
+		for _, roleID := range managedRoles {
+			if hasRole(member.User.ID, roleID) && !wanted[member.User.ID][roleID] {
+				changes = append(changes, roleChange{userID: member.User.ID, roleID: roleID, add: false,
+					description: "remove " + roleName(roleID) + " role from " + memberName(member.User.ID)})
+			}
+		}
+	}
+	for userID, roles := range wanted {
+		for roleID := range roles {
+			if !hasRole(userID, roleID) {
+				changes = append(changes, roleChange{userID: userID, roleID: roleID, add: true,
+					description: "give " + roleName(roleID) + " role to " + memberName(userID)})
+			}
+		}
+	}
+
+	if len(unknown) != 0 {
+		fmt.Println("These winners have no Discord ID in `member_ids', so you'll have to give them their roles yourself:")
+		for _, name := range unknown {
+			fmt.Println("\t- " + name)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("The board roles are already up to date.")
+		return
+	}
+
+	fmt.Println("Planned role changes:")
+	for _, change := range changes {
+		fmt.Println("\t- " + change.description)
+	}
+	confirm("Press [Enter] to apply these changes, or [Ctrl-C] to update the roles yourself: ")
+
+	for _, change := range changes {
+		method := "DELETE"
+		if change.add {
+			method = "PUT"
+		}
+		discordRequest(method, guildPath+"/members/"+change.userID+"/roles/"+change.roleID, nil)
+	}
+	fmt.Println("Updated the board roles.")
+}
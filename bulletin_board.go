@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// publish puts every counted ballot on the "Ballots" sheet of the results spreadsheet, without voters' emails, so anyone
+// can recompute the totals. Each ballot comes with a receipt, a hash committing to its contents, which is given to the
+// voter so they can find their ballot on the sheet and check that it was counted as they cast it. Ballots aren't
+// published when they could be matched to their voters, see ballotsIdentifiable.
+
+const bulletinFile = "state/bulletin.json"
+
+// {email, receipt} tuples for voters that couldn't be sent their receipt over Discord
+const receiptsFile = "state/receipts.csv"
+
+// shown after the receipt on the ballot server
+const receiptNote = ". Write it down: once the results are out, you can find it on the Ballots sheet of the results spreadsheet to check that your ballot was counted as you cast it."
+
+type bulletinBallot struct {
+	Receipt string  `json:"receipt"`
+	Nonce   string  `json:"nonce"`
+	Weight  float64 `json:"weight"`
+	// position => candidate => score, leaving out 0s
+	Scores map[string]map[string]uint `json:"scores"`
+}
+
+// ballotReceipt is the SHA-256 hash, in hex, of the nonce followed by a "<position>\t<candidate>\t<score>\n" line for
+// each nonzero score, in sorted order, and then "weight\t<weight>\n".
+func ballotReceipt(nonce string, weight float64, scores map[string]map[string]uint) string {
+	lines := []string{}
+	for position, candidates := range scores {
+		for candidate, score := range candidates {
+			if score != 0 {
+				lines = append(lines, position+"\t"+candidate+"\t"+fmt.Sprint(score)+"\n")
+			}
+		}
+	}
+	sort.Strings(lines)
+	hash := sha256.Sum256([]byte(nonce + strings.Join(lines, "") + "weight\t" + strconv.FormatFloat(weight, 'g', -1, 64) + "\n"))
+	return hex.EncodeToString(hash[:])
+}
+
+func newBulletinBallot(nonce string, weight float64, scores map[string]map[string]uint) bulletinBallot {
+	b := bulletinBallot{Receipt: ballotReceipt(nonce, weight, scores), Nonce: nonce, Weight: weight, Scores: make(map[string]map[string]uint)}
+	for position, candidates := range scores {
+		for candidate, score := range candidates {
+			if score == 0 {
+				continue
+			}
+			if b.Scores[position] == nil {
+				b.Scores[position] = make(map[string]uint)
+			}
+			b.Scores[position][candidate] = score
+		}
+	}
+	return b
+}
+
+// ballotsIdentifiable reports whether a published ballot could give away who cast it: with weighted voting, a voter
+// with an uncommon weight is picked out by their ballot's weight, and with electorates, the positions a ballot scores
+// narrow down who could have cast it.
+func ballotsIdentifiable() bool {
+	return usesWeights() || hasElectorates()
+}
+
+// publishesBallots reports whether publish adds the Ballots sheet and sends out receipts.
+func publishesBallots(results provisionalResults) bool {
+	return !ballotsIdentifiable() && disclosesAllTotals(results)
+}
+
+// sortBulletin orders ballots by receipt, which shuffles them since receipts are hashes.
+func sortBulletin(ballots []bulletinBallot) {
+	sort.Slice(ballots, func(i, j int) bool {
+		return ballots[i].Receipt < ballots[j].Receipt
+	})
+}
+
+// bulletinSheet lays out the ballots with one column per candidate, in the order of the results sheet. Every ballot
+// has a weight of 1, since ballots aren't published with weighted voting.
+func bulletinSheet(ballots []bulletinBallot, totalScores map[string]map[string]float64) *sheets.Sheet {
+	header := []string{"Receipt", "Nonce"}
+	columns := [][2]string{}
+	for _, position := range electionConfig.Positions {
+		candidates := []string{}
+		for candidate := range totalScores[position.Name] {
+			candidates = append(candidates, candidate)
+		}
+		sort.Strings(candidates)
+		for _, candidate := range candidates {
+			columns = append(columns, [2]string{position.Name, candidate})
+			header = append(header, position.Name+": "+candidate)
+		}
+	}
+
+	rowData := []*sheets.RowData{{}}
+	for _, title := range header {
+		title := title
+		rowData[0].Values = append(rowData[0].Values, &sheets.CellData{
+			UserEnteredValue:  &sheets.ExtendedValue{StringValue: &title},
+			UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}},
+		})
+	}
+	for _, ballot := range ballots {
+		receipt, nonce := ballot.Receipt, ballot.Nonce
+		row := &sheets.RowData{Values: []*sheets.CellData{
+			{UserEnteredValue: &sheets.ExtendedValue{StringValue: &receipt}},
+			{UserEnteredValue: &sheets.ExtendedValue{StringValue: &nonce}},
+		}}
+		for _, column := range columns {
+			score := float64(ballot.Scores[column[0]][column[1]])
+			row.Values = append(row.Values, &sheets.CellData{UserEnteredValue: &sheets.ExtendedValue{NumberValue: &score}})
+		}
+		rowData = append(rowData, row)
+	}
+
+	return &sheets.Sheet{
+		Properties: &sheets.SheetProperties{Title: "Ballots", GridProperties: &sheets.GridProperties{
+			RowCount:       int64(len(rowData)),
+			ColumnCount:    int64(len(header)),
+			FrozenRowCount: 1,
+		}},
+		Data: []*sheets.GridData{{RowData: rowData}},
+	}
+}
+
+// sendReceipts gives voters whose receipt was made at end-vote their receipt, over Discord if possible and otherwise
+// through state/receipts.csv.
+func sendReceipts(receipts [][2]string, spreadsheetURL string) {
+	undelivered := []string{}
+	for _, tuple := range receipts {
+		member := findMember(tuple[0])
+		if member != nil && member.DiscordID != 0 && discordConfig.BotToken != "" {
+			err := sendDirectMessage(member.DiscordID, "Your ballot receipt for the "+electionConfig.Name+" is `"+tuple[1]+"`. Find it on the Ballots sheet of "+spreadsheetURL+" to check that your ballot was counted as you cast it.")
+			if err == nil {
+				continue
+			}
+			fmt.Println("Couldn't DM " + tuple[0] + " their ballot receipt: " + err.Error())
+		}
+		undelivered = append(undelivered, tuple[0]+","+tuple[1])
+	}
+	if len(undelivered) == 0 {
+		return
+	}
+
+	err := os.WriteFile(receiptsFile, []byte("email,receipt\n"+strings.Join(undelivered, "\n")+"\n"), 0600)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(fmt.Sprint(len(undelivered)) + " voters couldn't be sent their ballot receipt over Discord. Email each of them their receipt from `" + receiptsFile + "', then delete it.")
+	confirm("Press [Enter] when you are done: ")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBallotReceipt(t *testing.T) {
+	hash := sha256.Sum256([]byte("nonce" + "P\tAnn\t2\n" + "P\tBob\t1\n" + "VP\tCat\t2\n" + "weight\t1\n"))
+	want := hex.EncodeToString(hash[:])
+
+	scores := map[string]map[string]uint{"VP": {"Cat": 2, "Dan": 0}, "P": {"Bob": 1, "Ann": 2}}
+	if got := ballotReceipt("nonce", 1, scores); got != want {
+		t.Errorf("ballotReceipt = %s, want %s", got, want)
+	}
+	// zero scores don't change the receipt, but everything else does
+	if got := ballotReceipt("nonce", 1, map[string]map[string]uint{"P": {"Ann": 2, "Bob": 1}, "VP": {"Cat": 2}}); got != want {
+		t.Errorf("ballotReceipt without zero scores = %s, want %s", got, want)
+	}
+	if ballotReceipt("other", 1, scores) == want || ballotReceipt("nonce", 0.5, scores) == want {
+		t.Error("ballotReceipt doesn't depend on the nonce and weight")
+	}
+
+	ballot := newBulletinBallot("nonce", 1, scores)
+	if ballot.Receipt != want || ballot.Scores["VP"]["Dan"] != 0 || len(ballot.Scores["VP"]) != 1 {
+		t.Errorf("newBulletinBallot = %+v", ballot)
+	}
+}
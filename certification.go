@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// end-vote only counts the votes into provisional results. With certifications_required set, that many officers must
+// each check them and sign them with their personal key using `certify' before `publish' announces them. Counting the
+// votes again changes the results, so earlier certifications no longer count.
+
+const provisionalFile = "state/provisional.json"
+const certificationsFile = "state/certifications.json"
+
+type rankedCandidate struct {
+	Name       string  `json:"name"`
+	Score      float64 `json:"score"`
+	Unweighted uint    `json:"unweighted"`
+}
+
+type provisionalResults struct {
+	BallotID string `json:"ballot_id"`
+	// position => candidate => score
+	Scores map[string]map[string]float64 `json:"scores"`
+	// position => candidates, highest score first
+	Rankings map[string][]rankedCandidate `json:"rankings"`
+	Weighted bool                         `json:"weighted"`
+	Winners  map[string]string            `json:"winners"`
+	// the first position with a tie, and the candidates that tied for it
+	Tie             string           `json:"tie"`
+	Tiers           []string         `json:"tiers"`
+	Votes           uint             `json:"votes"`
+	IneligibleVotes int              `json:"ineligible_votes"`
+	LateVotes       int              `json:"late_votes"`
+	Turnout         []string         `json:"turnout"`
+	Bulletin        []bulletinBallot `json:"bulletin"`
+	// {email, receipt} tuples for voters that haven't been given their receipt yet
+	Receipts [][2]string `json:"receipts"`
+	// position => number of ballots that voted for it
+	PositionVotes map[string]uint `json:"position_votes"`
+	// when publish may release the results, if they are embargoed
+	PublishAt string `json:"publish_at,omitempty"`
+}
+
+type certification struct {
+	Officer string `json:"officer"`
+	Time    string `json:"time"`
+	// Ed25519 signature of the SHA-256 hash of state/provisional.json
+	Signature []byte `json:"signature"`
+}
+
+type officerKey struct {
+	PublicKey string `json:"public_key"`
+	// encrypted with the officer's passphrase
+	PrivateKey sealedVault `json:"private_key"`
+}
+
+func saveProvisional(results provisionalResults) {
+	writeJSON(provisionalFile, results)
+	os.Remove(certificationsFile)
+	os.Remove(preparedFile)
+	audit("counted the votes, provisional results hash " + hex.EncodeToString(provisionalHash()))
+}
+
+func provisionalHash() []byte {
+	resultsBytes, err := os.ReadFile(provisionalFile)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(resultsBytes)
+	return hash[:]
+}
+
+func printProvisional(results provisionalResults) {
+	fmt.Println("Results (" + fmt.Sprint(results.Votes) + " votes):")
+	for _, position := range electionConfig.Positions {
+		fmt.Println("\t" + position.Name + ":")
+		for _, candidate := range results.Rankings[position.Name] {
+			line := "\t\t- " + candidate.Name + ": " + fmt.Sprint(candidate.Score)
+			if results.Weighted {
+				line += " (" + fmt.Sprint(candidate.Unweighted) + " unweighted)"
+			}
+			if results.Winners[position.Name] == candidate.Name {
+				line += ", elected"
+			}
+			fmt.Println(line)
+		}
+	}
+	if results.Tie != "" {
+		fmt.Println("There is a tie for " + results.Tie + " between " + strings.Join(results.Tiers, " and ") + ".")
+	}
+	if results.PublishAt != "" {
+		fmt.Println("To be published at " + results.PublishAt + ".")
+	}
+	if note := disclosureNote(results); note != "" {
+		fmt.Println(note)
+	}
+	fmt.Println()
+}
+
+// officerKeyPath is ELECTION_OFFICER_KEY if set, otherwise officer-NAME.key next to creds.json.
+func officerKeyPath(name string) string {
+	if path := os.Getenv("ELECTION_OFFICER_KEY"); path != "" {
+		return path
+	}
+	return filepath.Join(startDir, "officer-"+name+".key")
+}
+
+// handleOfficerKey creates an officer's signing key, protected by a passphrase.
+func handleOfficerKey(name string) {
+	path := officerKeyPath(name)
+	if _, err := os.Stat(path); err == nil {
+		fmt.Println("`" + path + "' already exists! Delete it first if you want a new key.")
+		os.Exit(1)
+	}
+
+	passphrase := readPassphrase("ELECTION_OFFICER_PASSPHRASE", "New passphrase for your key: ")
+	if os.Getenv("ELECTION_OFFICER_PASSPHRASE") == "" && readPassphrase("ELECTION_OFFICER_PASSPHRASE", "Repeat passphrase: ") != passphrase {
+		fmt.Println("The passphrases don't match.")
+		os.Exit(1)
+	}
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	keyBytes, err := json.Marshal(officerKey{PublicKey: hex.EncodeToString(publicKey), PrivateKey: seal(privateKey, passphrase)})
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(path, keyBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Saved your key to `" + path + "'. Add this line to `officer_keys' in the election config:")
+	fmt.Println("\t\"" + name + "\": \"" + hex.EncodeToString(publicKey) + "\"")
+	os.Exit(0)
+}
+
+func loadCertifications() []certification {
+	certifications := []certification{}
+	if _, err := os.Stat(certificationsFile); err == nil {
+		readJSON(certificationsFile, &certifications)
+	}
+	return certifications
+}
+
+// validCertifications lists the officers whose certification of the current provisional results checks out. Officers
+// sharing a key only count once.
+func validCertifications() []string {
+	hash := provisionalHash()
+	officers := []string{}
+	seenKeys := make(map[string]bool)
+	for _, c := range loadCertifications() {
+		keyHex, ok := electionConfig.OfficerKeys[c.Officer]
+		if !ok || seenKeys[keyHex] {
+			continue
+		}
+		publicKey, err := hex.DecodeString(keyHex)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, hash, c.Signature) {
+			continue
+		}
+		seenKeys[keyHex] = true
+		officers = append(officers, c.Officer)
+	}
+	return officers
+}
+
+// checkCertified exits unless enough officers have certified the provisional results.
+func checkCertified() {
+	required := electionConfig.CertificationsRequired
+	if required <= 0 {
+		return
+	}
+	officers := validCertifications()
+	if len(officers) < required {
+		certifiedBy := ""
+		if len(officers) != 0 {
+			certifiedBy = " (" + strings.Join(officers, ", ") + ")"
+		}
+		fmt.Println("Only " + fmt.Sprint(len(officers)) + " of the " + fmt.Sprint(required) + " officers needed have certified the results" + certifiedBy + ". Officers certify them with the `certify NAME' action.")
+		os.Exit(1)
+	}
+	audit("results certified by " + strings.Join(officers, ", "))
+}
+
+// handleCertify shows the provisional results to an officer, and signs them with the officer's key once they confirm.
+func handleCertify(name string) {
+	if _, err := os.Stat("state/results.txt"); err == nil {
+		fmt.Println("`state/results.txt' already exists, meaning the results have already been published!")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(provisionalFile); err != nil {
+		fmt.Println("`" + provisionalFile + "' does not exist, meaning the votes haven't been counted! Use the `end-vote' command to count them.")
+		os.Exit(1)
+	}
+	keyHex, ok := electionConfig.OfficerKeys[name]
+	if !ok {
+		fmt.Println("`" + name + "' isn't listed in `officer_keys'. Create a key with the `officer-key NAME' action and add it there.")
+		os.Exit(1)
+	}
+
+	path := officerKeyPath(name)
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Couldn't read your key from `" + path + "' (" + err.Error() + "). Create one with the `officer-key NAME' action, or set ELECTION_OFFICER_KEY.")
+		os.Exit(1)
+	}
+	checkPermissions(path)
+	var key officerKey
+	err = json.Unmarshal(keyBytes, &key)
+	if err != nil {
+		fmt.Println("`" + path + "' is not a valid officer key.")
+		os.Exit(1)
+	}
+	if key.PublicKey != keyHex {
+		fmt.Println("The key in `" + path + "' doesn't match the one listed for `" + name + "' in `officer_keys'.")
+		os.Exit(1)
+	}
+	privateKey := ed25519.PrivateKey(key.PrivateKey.open(readPassphrase("ELECTION_OFFICER_PASSPHRASE", "Passphrase for your key: "), path))
+	if len(privateKey) != ed25519.PrivateKeySize {
+		fmt.Println("`" + path + "' is not a valid officer key.")
+		os.Exit(1)
+	}
+
+	// sign exactly what was shown
+	resultsBytes, err := os.ReadFile(provisionalFile)
+	if err != nil {
+		panic(err)
+	}
+	var results provisionalResults
+	err = json.Unmarshal(resultsBytes, &results)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(resultsBytes)
+	printProvisional(results)
+	fmt.Println("Make sure these results are right, e.g. by running the `recount' action, before certifying them.")
+	confirm("Press [Enter] to certify these results as " + name + ", or [Ctrl-C] if something is wrong: ")
+
+	certifications := []certification{}
+	for _, c := range loadCertifications() {
+		if c.Officer != name {
+			certifications = append(certifications, c)
+		}
+	}
+	certifications = append(certifications, certification{
+		Officer:   name,
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		Signature: ed25519.Sign(privateKey, hash[:]),
+	})
+	writeJSON(certificationsFile, certifications)
+	audit("certified the results as " + name)
+
+	fmt.Println(fmt.Sprint(len(validCertifications())) + " of the " + fmt.Sprint(electionConfig.CertificationsRequired) + " officers needed have certified the results.")
+	os.Exit(0)
+}
@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+// In a small electorate, detailed scores can give away how individual members voted. `disclosure' limits what publish
+// shows on the results spreadsheet and in the Discord announcement; officers still see everything locally.
+
+const (
+	discloseTotals  = "totals"
+	discloseRank    = "rank"
+	discloseWinners = "winners"
+)
+
+// positionDisclosure is how much of a position's results are published.
+func positionDisclosure(results provisionalResults, position string) string {
+	switch electionConfig.Disclosure {
+	case discloseRank, discloseWinners:
+		return electionConfig.Disclosure
+	}
+	if results.PositionVotes[position] < electionConfig.MinPositionVotes {
+		return discloseRank
+	}
+	return discloseTotals
+}
+
+// disclosesAllTotals reports whether the scores of every position are published. The ballots add up to the scores, so
+// they and their receipts are only published if so.
+func disclosesAllTotals(results provisionalResults) bool {
+	for _, position := range electionConfig.Positions {
+		if positionDisclosure(results, position.Name) != discloseTotals {
+			return false
+		}
+	}
+	return true
+}
+
+// disclosureNote explains which positions' scores were withheld, or is empty if none were.
+func disclosureNote(results provisionalResults) string {
+	rankOnly := []string{}
+	winnersOnly := []string{}
+	for _, position := range electionConfig.Positions {
+		switch positionDisclosure(results, position.Name) {
+		case discloseRank:
+			rankOnly = append(rankOnly, position.Name)
+		case discloseWinners:
+			winnersOnly = append(winnersOnly, position.Name)
+		}
+	}
+
+	lines := []string{}
+	if len(rankOnly) != 0 {
+		lines = append(lines, "Only the order of the candidates is published for "+strings.Join(rankOnly, ", ")+".")
+	}
+	if len(winnersOnly) != 0 {
+		lines = append(lines, "Only the winners are published for "+strings.Join(winnersOnly, ", ")+".")
+	}
+	if len(lines) != 0 {
+		lines = append(lines, "Scores are withheld to keep individual ballots secret.")
+	}
+	return strings.Join(lines, "\n")
+}
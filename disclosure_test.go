@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestPositionDisclosure(t *testing.T) {
+	results := provisionalResults{PositionVotes: map[string]uint{"P": 10, "VP": 2}}
+	tests := []struct {
+		disclosure string
+		minVotes   uint
+		want       map[string]string
+		allTotals  bool
+	}{
+		{"", 0, map[string]string{"P": discloseTotals, "VP": discloseTotals}, true},
+		{discloseTotals, 5, map[string]string{"P": discloseTotals, "VP": discloseRank}, false},
+		{discloseRank, 0, map[string]string{"P": discloseRank, "VP": discloseRank}, false},
+		{discloseWinners, 5, map[string]string{"P": discloseWinners, "VP": discloseWinners}, false},
+	}
+	for _, test := range tests {
+		useConfig(t, Config{Positions: []Position{{Name: "P"}, {Name: "VP"}}, Disclosure: test.disclosure, MinPositionVotes: test.minVotes})
+		for position, want := range test.want {
+			if got := positionDisclosure(results, position); got != want {
+				t.Errorf("disclosure %q, min %d: %s is %q, want %q", test.disclosure, test.minVotes, position, got, want)
+			}
+		}
+		if got := disclosesAllTotals(results); got != test.allTotals {
+			t.Errorf("disclosure %q, min %d: disclosesAllTotals = %v, want %v", test.disclosure, test.minVotes, got, test.allTotals)
+		}
+		if (disclosureNote(results) == "") != test.allTotals {
+			t.Errorf("disclosure %q, min %d: note %q", test.disclosure, test.minVotes, disclosureNote(results))
+		}
+	}
+}
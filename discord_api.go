@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func discordAPI() string {
+	if discordConfig.APIURL != "" {
+		return strings.TrimSuffix(discordConfig.APIURL, "/")
+	}
+	return "https://discord.com/api/v10"
+}
+
+// discordRequest calls the Discord REST API as the bot and returns the response body.
+func discordRequest(method string, path string, body interface{}) []byte {
+	respBody, err := tryDiscordRequest(method, path, body)
+	if err != nil {
+		panic(err)
+	}
+	return respBody
+}
+
+// tryDiscordRequest is discordRequest for calls that may fail without stopping the action, e.g. a DM to a member that
+// doesn't accept DMs.
+func tryDiscordRequest(method string, path string, body interface{}) ([]byte, error) {
+	if discordConfig.BotToken == "" {
+		fmt.Println("`bot_token' is not set in `config/discord.json', so the bot can't talk to Discord directly.")
+		os.Exit(1)
+	}
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, discordAPI()+path, bytes.NewReader(reqBody))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Authorization", "Bot "+discordConfig.BotToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("discord: %s %s: %s: %s", method, path, resp.Status, respBody)
+	}
+	if method != "GET" {
+		audit("Discord API: " + method + " " + path)
+	}
+	return respBody, nil
+}
+
+// sendDirectMessage DMs a member. It fails if, for example, the member doesn't accept DMs from server members.
+func sendDirectMessage(userID uint64, text string) error {
+	var channel struct {
+		ID string `json:"id"`
+	}
+	channelBytes, err := tryDiscordRequest("POST", "/users/@me/channels", map[string]interface{}{
+		"recipient_id": strconv.FormatUint(userID, 10),
+	})
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(channelBytes, &channel)
+	if err != nil {
+		return err
+	}
+
+	_, err = tryDiscordRequest("POST", "/channels/"+channel.ID+"/messages", map[string]interface{}{
+		"content": text,
+	})
+	return err
+}
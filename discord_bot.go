@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// written by start-vote so that the bot and ballot server can list candidates without access to the forms
+type positionCandidates struct {
+	Position   string   `json:"position"`
+	Candidates []string `json:"candidates"`
+}
+
+func writeCandidates(applicantsByPosition map[string][]string) {
+	candidates := []positionCandidates{}
+	for _, position := range electionConfig.Positions {
+		candidates = append(candidates, positionCandidates{Position: position.Name, Candidates: applicantsByPosition[position.Name]})
+	}
+	candidatesBytes, err := json.Marshal(candidates)
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile("state/candidates.json", candidatesBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type interactionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   interface{}         `json:"value"`
+	Options []interactionOption `json:"options"`
+}
+
+type interaction struct {
+	Type   int `json:"type"`
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+	Data struct {
+		Name    string              `json:"name"`
+		Options []interactionOption `json:"options"`
+	} `json:"data"`
+}
+
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+
+	responsePong    = 1
+	responseMessage = 4
+
+	messageEphemeral = 1 << 6
+)
+
+// actions that officers may run from Discord
+var botActions = []string{"start-application", "start-vote", "end-vote", "publish", "turnout"}
+
+var actionMutex sync.Mutex
+var actionRunning bool
+
+func handleBot() {
+	if discordConfig.ApplicationID == 0 || discordConfig.GuildID == 0 || discordConfig.PublicKey == "" {
+		fmt.Println("`application_id', `guild_id' and `public_key' must be set in `config/discord.json' to run the bot.")
+		os.Exit(1)
+	}
+	publicKey, err := hex.DecodeString(discordConfig.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		fmt.Println("`public_key' in `config/discord.json' is not a valid Ed25519 public key.")
+		os.Exit(1)
+	}
+
+	registerCommands()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/interactions", interactionsHandler(publicKey)).Methods("POST")
+
+	addr := discordConfig.InteractionsAddr
+	if addr == "" {
+		addr = "localhost:4445"
+	}
+	fmt.Println("Listening for interactions on http://" + addr + "/interactions")
+	panic(http.ListenAndServe(addr, r))
+}
+
+// interactionsHandler answers the interactions Discord sends, after checking that Discord signed them with publicKey.
+func interactionsHandler(publicKey ed25519.PublicKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		signature, err := hex.DecodeString(req.Header.Get("X-Signature-Ed25519"))
+		if err != nil || !ed25519.Verify(publicKey, append([]byte(req.Header.Get("X-Signature-Timestamp")), body...), signature) {
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		var i interaction
+		err = json.Unmarshal(body, &i)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var resp map[string]interface{}
+		switch i.Type {
+		case interactionPing:
+			resp = map[string]interface{}{"type": responsePong}
+		case interactionApplicationCommand:
+			resp = map[string]interface{}{
+				"type": responseMessage,
+				"data": map[string]interface{}{
+					"content": handleCommand(&i),
+					"flags":   messageEphemeral,
+				},
+			}
+		default:
+			http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func registerCommands() {
+	actionChoices := []map[string]interface{}{}
+	for _, action := range botActions {
+		actionChoices = append(actionChoices, map[string]interface{}{"name": action, "value": action})
+	}
+
+	discordRequest("PUT", "/applications/"+fmt.Sprint(discordConfig.ApplicationID)+"/guilds/"+fmt.Sprint(discordConfig.GuildID)+"/commands", []map[string]interface{}{{
+		"name":        "election",
+		"description": electionConfig.Name,
+		"options": []map[string]interface{}{{
+			"type":        1,
+			"name":        "status",
+			"description": "Where the election is at",
+		}, {
+			"type":        1,
+			"name":        "am-i-eligible",
+			"description": "Check whether an email address can vote or run",
+			"options": []map[string]interface{}{{
+				"type":        3,
+				"name":        "email",
+				"description": "The email address you would apply or vote with",
+				"required":    true,
+			}},
+		}, {
+			"type":        1,
+			"name":        "candidates",
+			"description": "List the candidates for each position",
+		}, {
+			"type":        1,
+			"name":        "run",
+			"description": "Run an election action (officers only)",
+			"options": []map[string]interface{}{{
+				"type":        3,
+				"name":        "action",
+				"description": "The action to run",
+				"required":    true,
+				"choices":     actionChoices,
+			}},
+		}},
+	}})
+}
+
+// handleCommand returns the reply to an /election command.
+func handleCommand(i *interaction) string {
+	if i.Data.Name != "election" || len(i.Data.Options) == 0 {
+		return "Unknown command."
+	}
+	subcommand := i.Data.Options[0]
+	option := func(name string) string {
+		for _, opt := range subcommand.Options {
+			if opt.Name == name {
+				return fmt.Sprint(opt.Value)
+			}
+		}
+		return ""
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = &i.Member.User
+	}
+
+	switch subcommand.Name {
+	case "status":
+		return electionStatus()
+	case "am-i-eligible":
+		email := option("email")
+		canVote := isEligibleVoter(email)
+		canApply := isEligibleApplicant(email)
+		switch {
+		case canVote && canApply:
+			return "`" + email + "` can vote and run for a position."
+		case canVote:
+			return "`" + email + "` can vote, but can't run for a position."
+		case canApply:
+			return "`" + email + "` can run for a position, but can't vote."
+		default:
+			return "`" + email + "` can't vote or run for a position. If you think this is a mistake, contact an election officer."
+		}
+	case "candidates":
+		candidatesBytes, err := os.ReadFile("state/candidates.json")
+		if err != nil {
+			return "Candidates will be listed once voting begins."
+		}
+		var candidates []positionCandidates
+		err = json.Unmarshal(candidatesBytes, &candidates)
+		if err != nil {
+			panic(err)
+		}
+		reply := "Candidates for the " + electionConfig.Name + ":"
+		for _, position := range candidates {
+			reply += "\n**" + position.Position + "**: " + strings.Join(position.Candidates, ", ")
+		}
+		return reply
+	case "run":
+		if user == nil || !isOfficer(user.ID) {
+			return "Only election officers can do that."
+		}
+		action := option("action")
+		valid := false
+		for _, botAction := range botActions {
+			if action == botAction {
+				valid = true
+			}
+		}
+		if !valid {
+			return "Unknown action `" + action + "`."
+		}
+		actionMutex.Lock()
+		defer actionMutex.Unlock()
+		if actionRunning {
+			return "Another action is already running."
+		}
+		actionRunning = true
+		go runAction(action, user)
+		return "Running `" + action + "`. Continue in the terminal the bot is running in."
+	}
+	return "Unknown command."
+}
+
+func isOfficer(userID string) bool {
+	for _, officerID := range discordConfig.OfficerIDs {
+		if strconv.FormatUint(officerID, 10) == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// electionStatus describes the election phase based on the state folder.
+func electionStatus() string {
+	exists := func(name string) bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}
+	switch {
+	case exists("state/results.txt"):
+		return "The " + electionConfig.Name + " is over and the results are out."
+	case exists(provisionalFile):
+		var results provisionalResults
+		readJSON(provisionalFile, &results)
+		if results.PublishAt != "" {
+			return "Voting for the " + electionConfig.Name + " has closed. The results will be announced <t:" + fmt.Sprint(parseDeadline(results.PublishAt).Unix()) + ":F>."
+		}
+		return "Voting for the " + electionConfig.Name + " has closed, and the votes are being counted."
+	case exists("state/ballot.txt"):
+		status := "Voting for the " + electionConfig.Name + " is open."
+		if electionConfig.VoteDeadline != "" {
+			status += " The deadline is <t:" + fmt.Sprint(voteDeadline.Unix()) + ":F>."
+		}
+		return status
+	case exists("state/application.txt"):
+		status := "Applications for the " + electionConfig.Name + " are open."
+		if electionConfig.ApplicationDeadline != "" {
+			status += " The deadline is <t:" + fmt.Sprint(applicationDeadline.Unix()) + ":F>."
+		}
+		return status
+	default:
+		return "The " + electionConfig.Name + " hasn't started yet."
+	}
+}
+
+// runAction runs an action in a child process attached to the bot's terminal, since actions need the officer to
+// authorize Google and confirm prompts there.
+func runAction(action string, officer *discordUser) {
+	defer func() {
+		actionMutex.Lock()
+		actionRunning = false
+		actionMutex.Unlock()
+	}()
+
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	args := []string{action}
+	if electionDir != "" {
+		args = append(args, "--election", electionDir)
+	}
+	cmd := exec.Command(executable, args...)
+	// loadConfig changed into the election's folder, but creds.json is found relative to where the bot was started
+	cmd.Dir = startDir
+	// recorded as the officer in the audit log
+	cmd.Env = append(os.Environ(), "ELECTION_OFFICER=discord:"+officer.Username+" ("+officer.ID+")")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		fmt.Println("`" + action + "' failed: " + err.Error())
+	}
+}
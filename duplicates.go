@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/forms/v1"
+)
+
+// a roster member that responded from more than one address
+type duplicate struct {
+	member *Member
+	// lowercased addresses the member responded from
+	emails []string
+}
+
+// dedupeResponses keeps one response per roster member. Which one is kept depends on `duplicate_policy': "latest" (the
+// default) keeps the most recently submitted response, and "earliest" keeps the first one. Every response must be
+// from a roster member.
+func dedupeResponses(responses []*forms.FormResponse) ([]*forms.FormResponse, []duplicate) {
+	byMember := make(map[*Member][]*forms.FormResponse)
+	members := []*Member{}
+	for _, resp := range responses {
+		member := findMember(resp.RespondentEmail)
+		if _, ok := byMember[member]; !ok {
+			members = append(members, member)
+		}
+		byMember[member] = append(byMember[member], resp)
+	}
+
+	kept := []*forms.FormResponse{}
+	duplicates := []duplicate{}
+	for _, member := range members {
+		memberResponses := byMember[member]
+		sort.SliceStable(memberResponses, func(i, j int) bool {
+			return submittedAt(memberResponses[i]).Before(submittedAt(memberResponses[j]))
+		})
+		if duplicatePolicy() == "earliest" {
+			kept = append(kept, memberResponses[0])
+		} else {
+			kept = append(kept, memberResponses[len(memberResponses)-1])
+		}
+
+		if len(memberResponses) > 1 {
+			d := duplicate{member: member}
+			for _, resp := range memberResponses {
+				d.emails = append(d.emails, strings.ToLower(resp.RespondentEmail))
+			}
+			duplicates = append(duplicates, d)
+		}
+	}
+	return kept, duplicates
+}
+
+func duplicatePolicy() string {
+	if electionConfig.DuplicatePolicy == "earliest" {
+		return "earliest"
+	}
+	return "latest"
+}
+
+func submittedAt(resp *forms.FormResponse) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, resp.LastSubmittedTime)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
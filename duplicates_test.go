@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/forms/v1"
+)
+
+func TestDedupeResponses(t *testing.T) {
+	useRoster(t, &Member{Email: "a@gmail.com", Vote: true}, &Member{Email: "b@example.org", Vote: true})
+	responses := []*forms.FormResponse{
+		{ResponseId: "a2", RespondentEmail: "A.@gmail.com", LastSubmittedTime: "2024-01-02T00:00:00Z"},
+		{ResponseId: "b", RespondentEmail: "b@example.org", LastSubmittedTime: "2024-01-01T12:00:00Z"},
+		{ResponseId: "a1", RespondentEmail: "a+vote@gmail.com", LastSubmittedTime: "2024-01-01T00:00:00Z"},
+	}
+
+	tests := []struct {
+		policy string
+		kept   string
+	}{
+		{"", "a2"},
+		{"latest", "a2"},
+		{"earliest", "a1"},
+	}
+	for _, test := range tests {
+		electionConfig.DuplicatePolicy = test.policy
+		kept, duplicates := dedupeResponses(responses)
+		if len(kept) != 2 || kept[0].ResponseId != test.kept || kept[1].ResponseId != "b" {
+			ids := []string{}
+			for _, resp := range kept {
+				ids = append(ids, resp.ResponseId)
+			}
+			t.Errorf("policy %q kept %v, want [%s b]", test.policy, ids, test.kept)
+		}
+		if len(duplicates) != 1 || duplicates[0].member.Email != "a@gmail.com" || len(duplicates[0].emails) != 2 {
+			t.Errorf("policy %q found duplicates %+v", test.policy, duplicates)
+		}
+	}
+	electionConfig.DuplicatePolicy = ""
+}
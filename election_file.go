@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// the single-file config; if it doesn't exist, the separate files in config/ are read instead
+const electionFile = "config/election.toml"
+
+type electionFileConfig struct {
+	Config
+	Discord DiscordConfig `toml:"discord"`
+
+	// the rolls, in order of preference: a roster file, an inline roster, or lists of addresses (inline and/or in
+	// files). Paths are relative to config/.
+	RosterFile     string    `toml:"roster_file"`
+	Members        []*Member `toml:"members"`
+	Voters         []string  `toml:"voters"`
+	VotersFile     string    `toml:"voters_file"`
+	Applicants     []string  `toml:"applicants"`
+	ApplicantsFile string    `toml:"applicants_file"`
+}
+
+func loadElectionFile() {
+	var f electionFileConfig
+	_, err := toml.DecodeFile(electionFile, &f)
+	if err != nil {
+		panic(err)
+	}
+	electionConfig = f.Config
+	discordConfig = f.Discord
+
+	if f.RosterFile != "" {
+		roster = readRosterFile(filepath.Join("config", f.RosterFile))
+	} else if len(f.Members) != 0 {
+		roster = f.Members
+	} else {
+		voters := f.Voters
+		if f.VotersFile != "" {
+			voters = append(voters, readList(filepath.Join("config", f.VotersFile))...)
+		}
+		applicants := f.Applicants
+		if f.ApplicantsFile != "" {
+			applicants = append(applicants, readList(filepath.Join("config", f.ApplicantsFile))...)
+		}
+		roster = rosterFromLists(voters, applicants)
+	}
+}
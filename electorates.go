@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func findPosition(name string) *Position {
+	for i := range electionConfig.Positions {
+		if electionConfig.Positions[i].Name == name {
+			return &electionConfig.Positions[i]
+		}
+	}
+	return nil
+}
+
+// canVoteFor reports whether a member may vote for a position. Positions without an electorate are open to every
+// eligible voter; otherwise the member must be in one of the electorate's roster groups.
+func canVoteFor(position *Position, member *Member) bool {
+	if member == nil || !member.Vote {
+		return false
+	}
+	if position == nil || len(position.Electorate) == 0 {
+		return true
+	}
+	for _, group := range position.Electorate {
+		for _, memberGroup := range member.Groups {
+			if strings.EqualFold(group, memberGroup) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasElectorates() bool {
+	for _, position := range electionConfig.Positions {
+		if len(position.Electorate) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// positionTurnout describes how many members of each position's electorate have voted.
+func positionTurnout(voted map[*Member]bool) []string {
+	lines := []string{}
+	for i := range electionConfig.Positions {
+		position := &electionConfig.Positions[i]
+		eligible := 0
+		positionVoted := 0
+		for _, member := range roster {
+			if !canVoteFor(position, member) {
+				continue
+			}
+			eligible += 1
+			if voted[member] {
+				positionVoted += 1
+			}
+		}
+		lines = append(lines, position.Name+": "+fmt.Sprint(positionVoted)+" of "+fmt.Sprint(eligible))
+	}
+	return lines
+}
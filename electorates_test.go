@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestCanVoteFor(t *testing.T) {
+	everyone := &Position{Name: "P"}
+	build := &Position{Name: "Build Lead", Electorate: []string{"build", "cad"}}
+	tests := []struct {
+		member   *Member
+		position *Position
+		want     bool
+	}{
+		{&Member{Vote: true}, everyone, true},
+		{&Member{Vote: true}, build, false},
+		{&Member{Vote: true, Groups: []string{"Build"}}, build, true},
+		{&Member{Vote: true, Groups: []string{"programming", "CAD"}}, build, true},
+		{&Member{Vote: true, Groups: []string{"programming"}}, build, false},
+		{&Member{Groups: []string{"build"}}, build, false},
+		{nil, everyone, false},
+	}
+	for _, test := range tests {
+		if got := canVoteFor(test.position, test.member); got != test.want {
+			t.Errorf("canVoteFor(%s, %+v) = %v, want %v", test.position.Name, test.member, got, test.want)
+		}
+	}
+}
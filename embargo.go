@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// `end-vote --publish-at TIME' schedules the results to be announced at TIME, e.g. during a team meeting. `publish'
+// then prepares the results spreadsheet without sharing it, shows the officer a preview, and waits until TIME to share
+// the spreadsheet and post the results. Run from the bot, the bot's terminal does the waiting.
+
+// the spreadsheet prepared by publish, kept private until the results are released
+const preparedFile = "state/prepared.txt"
+
+// parsePublishAt parses the --publish-at option, which is written like the deadlines in the config.
+func parsePublishAt(publishAt string) time.Time {
+	t, err := time.Parse(time.RFC3339, publishAt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "--publish-at needs a time like 2006-01-02T15:04:05-07:00: "+err.Error())
+		os.Exit(2)
+	}
+	if !t.After(time.Now()) {
+		fmt.Fprintln(os.Stderr, "--publish-at "+publishAt+" has already passed.")
+		os.Exit(2)
+	}
+	return t
+}
+
+// embargoed reports whether results scheduled for publishAt can't be released yet.
+func embargoed(publishAt string) bool {
+	return publishAt != "" && time.Now().Before(parseDeadline(publishAt))
+}
+
+// previewEmbed prints a Discord message the way it will be posted.
+func previewEmbed(text string, embed *DiscordEmbed) {
+	fmt.Println("Discord message preview:")
+	fmt.Println("\t" + text)
+	fmt.Println("\t**" + embed.Title + "**")
+	fmt.Println("\t" + embed.Description)
+	for _, field := range embed.Fields {
+		fmt.Println("\t" + field.Name + ": " + field.Value)
+	}
+	fmt.Println()
+}
+
+// waitUntil blocks until publishAt, printing how long is left every so often.
+func waitUntil(publishAt string) {
+	releaseTime := parseDeadline(publishAt)
+	audit("waiting to release the results at " + publishAt)
+	for left := time.Until(releaseTime); left > 0; left = time.Until(releaseTime) {
+		fmt.Println("Releasing the results in " + left.Round(time.Second).String() + ". Keep this running; if it stops, run the `publish' action again.")
+		if left > time.Hour {
+			left = time.Hour
+		}
+		time.Sleep(left)
+	}
+}
+
+// shareSpreadsheet makes a spreadsheet viewable by anyone with the link.
+func shareSpreadsheet(client *http.Client, spreadsheetID string) {
+	driveService, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		panic(err)
+	}
+	_, err = driveService.Permissions.Create(spreadsheetID, &drive.Permission{Type: "anyone", Role: "reader"}).Do()
+	if err != nil {
+		panic(err)
+	}
+	audit("made results spreadsheet " + spreadsheetID + " publicly viewable")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// The Forms client library the forms are made with predates the API's email collection and publish settings, so those
+// are set and read back with plain requests. The API has no settings for editing responses or limiting forms to one
+// response per person, so the officer still turns those on by hand.
+
+const formsAPI = "https://forms.googleapis.com/v1/forms/"
+
+type formPublishState struct {
+	IsPublished          bool `json:"isPublished"`
+	IsAcceptingResponses bool `json:"isAcceptingResponses"`
+}
+
+// the parts of a form that configureForm checks
+type formSettings struct {
+	Settings struct {
+		EmailCollectionType string `json:"emailCollectionType"`
+	} `json:"settings"`
+	// missing for forms made before forms could be unpublished
+	PublishSettings *struct {
+		PublishState *formPublishState `json:"publishState"`
+	} `json:"publishSettings"`
+}
+
+func formsRequest(client *http.Client, method string, path string, body interface{}) ([]byte, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, formsAPI+path, bytes.NewReader(reqBody))
+	if err != nil {
+		panic(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("forms: %s %s: %s: %s", method, path, resp.Status, respBody)
+	}
+	if method != "GET" {
+		audit("Forms API: " + method + " " + path)
+	}
+	return respBody, nil
+}
+
+// configureForm turns on email collection (if collectEmails) and opens or closes a form, then reads the form back,
+// asking the officer to fix whatever didn't take until everything checks out.
+func configureForm(client *http.Client, formID string, formName string, collectEmails bool, accepting bool) {
+	editURL := "https://docs.google.com/forms/d/" + formID + "/edit"
+
+	if collectEmails {
+		// the address is the Google account the respondent is signed in with, so nobody can respond as someone else
+		_, err := formsRequest(client, "POST", formID+":batchUpdate", map[string]interface{}{
+			"requests": []interface{}{map[string]interface{}{
+				"updateSettings": map[string]interface{}{
+					"settings":   map[string]interface{}{"emailCollectionType": "VERIFIED"},
+					"updateMask": "emailCollectionType",
+				},
+			}},
+		})
+		if err != nil {
+			fmt.Println("Couldn't turn on email collection for the " + formName + ": " + err.Error())
+		}
+	}
+	_, err := formsRequest(client, "POST", formID+":setPublishSettings", map[string]interface{}{
+		"publishSettings": map[string]interface{}{
+			"publishState": formPublishState{IsPublished: true, IsAcceptingResponses: accepting},
+		},
+		"updateMask": "publishState",
+	})
+	if err != nil {
+		fmt.Println("Couldn't change whether the " + formName + " accepts responses: " + err.Error())
+	}
+
+	checkedByHand := false
+	for {
+		formBytes, err := formsRequest(client, "GET", formID, nil)
+		if err != nil {
+			panic(err)
+		}
+		var settings formSettings
+		err = json.Unmarshal(formBytes, &settings)
+		if err != nil {
+			panic(err)
+		}
+
+		problems := []string{}
+		if collectEmails && settings.Settings.EmailCollectionType != "VERIFIED" {
+			problems = append(problems, "Set 'Collect email addresses' to 'Verified'")
+		}
+		if settings.PublishSettings == nil || settings.PublishSettings.PublishState == nil {
+			// older forms don't say whether they accept responses, so the officer has to check
+			if !checkedByHand {
+				state := "closed"
+				if accepting {
+					state = "accepting responses"
+				}
+				fmt.Println("Make sure the " + formName + " (" + editURL + ") is " + state + ".")
+				confirm("Press [Enter] when you are done: ")
+				checkedByHand = true
+			}
+		} else if state := settings.PublishSettings.PublishState; accepting && !(state.IsPublished && state.IsAcceptingResponses) {
+			problems = append(problems, "Publish the form and turn on 'Accepting responses'")
+		} else if !accepting && state.IsAcceptingResponses {
+			problems = append(problems, "Turn off 'Accepting responses'")
+		}
+
+		if len(problems) == 0 {
+			audit("checked the settings of the " + formName + " " + formID)
+			return
+		}
+		fmt.Println("The " + formName + " isn't set up right yet. At " + editURL + ":")
+		for _, problem := range problems {
+			fmt.Println("\t- " + problem)
+		}
+		confirm("When you're done, press [Enter] to check again: ")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const historyFile = "archive/history.json"
+
+type pastWinner struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+type pastElection struct {
+	Name string `json:"name"`
+	// YYYY-MM-DD
+	Date string `json:"date"`
+	// position => winner
+	Winners map[string]pastWinner `json:"winners"`
+	// where the election was archived, once it has been
+	Archive string `json:"archive,omitempty"`
+}
+
+// TermLimit limits how many elections in a row someone may win a position.
+type TermLimit struct {
+	MaxConsecutive int `json:"max_consecutive" toml:"max_consecutive"`
+	// positions that count towards the limit, e.g. both presidencies; defaults to the position itself
+	Positions []string `json:"positions" toml:"positions"`
+}
+
+// loadHistory returns past elections, oldest first.
+func loadHistory() []pastElection {
+	history := []pastElection{}
+	historyBytes, err := os.ReadFile(historyFile)
+	if os.IsNotExist(err) {
+		return history
+	} else if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(historyBytes, &history)
+	if err != nil {
+		panic(err)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Date < history[j].Date
+	})
+	return history
+}
+
+// recordWinners adds this election's winners to the history used for term limits.
+func recordWinners(winners map[string]string) {
+	election := pastElection{
+		Name:    electionConfig.Name,
+		Date:    time.Now().Format(dateLayout),
+		Winners: make(map[string]pastWinner),
+	}
+	for position, name := range winners {
+		winner := pastWinner{Name: name}
+		if member := findMemberByName(name); member != nil {
+			winner.Email = member.Email
+		}
+		election.Winners[position] = winner
+	}
+
+	saveHistory(append(loadHistory(), election))
+}
+
+// handleRecordWinners records the winners of an election that ended in a tie, once the runoff (or the officers) have
+// decided it. The officer types in the winner of every position publish couldn't decide.
+func handleRecordWinners() {
+	if _, err := os.Stat("state/results.txt"); err != nil {
+		fmt.Println("`state/results.txt' does not exist, meaning the results haven't been published yet! Use the `publish' action first.")
+		os.Exit(1)
+	}
+	var results provisionalResults
+	readJSON(provisionalFile, &results)
+	if results.Tie == "" {
+		fmt.Println("There was no tie, so `publish' already recorded the winners.")
+		os.Exit(1)
+	}
+
+	winners := make(map[string]string)
+	for position, winner := range results.Winners {
+		winners[position] = winner
+	}
+	input := bufio.NewReader(os.Stdin)
+	for _, position := range electionConfig.Positions {
+		if _, ok := winners[position.Name]; ok {
+			continue
+		}
+		candidates := []string{}
+		for _, candidate := range results.Rankings[position.Name] {
+			candidates = append(candidates, candidate.Name)
+		}
+		for {
+			fmt.Print("Who won " + position.Name + "? (" + strings.Join(candidates, ", ") + "): ")
+			line, _ := input.ReadString('\n')
+			name := ""
+			for _, candidate := range candidates {
+				if strings.EqualFold(candidate, strings.TrimSpace(line)) {
+					name = candidate
+				}
+			}
+			if name != "" {
+				winners[position.Name] = name
+				break
+			}
+			fmt.Println("`" + strings.TrimSpace(line) + "' isn't a candidate for " + position.Name + ".")
+		}
+	}
+
+	fmt.Println("Winners:")
+	for _, position := range electionConfig.Positions {
+		fmt.Println("\t- " + position.Name + ": " + winners[position.Name])
+	}
+	confirm("Press [Enter] to record them: ")
+	recordWinners(winners)
+	audit("recorded the winners decided after the tie for " + results.Tie)
+
+	if discordConfig.BotToken != "" && discordConfig.GuildID != 0 && knowDiscordIDs() {
+		updateBoardRoles(winners)
+		fmt.Println("You're all set!")
+	} else {
+		fmt.Println("You're all set! Make sure you update the board roles.")
+	}
+	os.Exit(0)
+}
+
+func saveHistory(history []pastElection) {
+	historyBytes, err := json.MarshalIndent(history, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	os.MkdirAll("archive", 0700)
+	err = os.WriteFile(historyFile, historyBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (w *pastWinner) is(member *Member, name string) bool {
+	if member != nil && w.Email != "" {
+		return normalizeEmail(w.Email) == normalizeEmail(member.Email)
+	}
+	return strings.EqualFold(strings.TrimSpace(w.Name), strings.TrimSpace(name))
+}
+
+// termLimitViolation returns why an applicant can't run for a position because of its term limit, or "" if they can.
+func termLimitViolation(positionName string, member *Member, name string) string {
+	position := findPosition(positionName)
+	if position == nil || position.TermLimit == nil || position.TermLimit.MaxConsecutive <= 0 {
+		return ""
+	}
+	offices := position.TermLimit.Positions
+	if len(offices) == 0 {
+		offices = []string{position.Name}
+	}
+
+	// walk back through the elections that filled any of the offices
+	history := loadHistory()
+	consecutive := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		filled := false
+		held := false
+		for _, office := range offices {
+			if winner, ok := history[i].Winners[office]; ok {
+				filled = true
+				if winner.is(member, name) {
+					held = true
+				}
+			}
+		}
+		if !filled {
+			continue
+		}
+		if !held {
+			break
+		}
+		consecutive += 1
+	}
+
+	if consecutive >= position.TermLimit.MaxConsecutive {
+		return "has already served " + fmt.Sprint(consecutive) + " consecutive terms as " + strings.Join(offices, "/") +
+			" (the limit is " + fmt.Sprint(position.TermLimit.MaxConsecutive) + ")"
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestTermLimitViolation(t *testing.T) {
+	election := func(date string, winners map[string]string) pastElection {
+		e := pastElection{Name: "Election", Date: date, Winners: make(map[string]pastWinner)}
+		for position, name := range winners {
+			e.Winners[position] = pastWinner{Name: name}
+		}
+		return e
+	}
+	tests := []struct {
+		name    string
+		history []pastElection
+		limited bool
+	}{
+		{"no history", nil, false},
+		{"one term", []pastElection{election("2023-05-01", map[string]string{"President": "Ann"})}, false},
+		{"two terms", []pastElection{
+			election("2022-05-01", map[string]string{"President": "Ann"}),
+			election("2023-05-01", map[string]string{"President": "Ann"}),
+		}, true},
+		{"across counted positions", []pastElection{
+			election("2022-05-01", map[string]string{"Co-President": "ann"}),
+			election("2023-05-01", map[string]string{"President": "Ann"}),
+		}, true},
+		{"skipping elections that didn't fill the position", []pastElection{
+			election("2021-05-01", map[string]string{"President": "Ann"}),
+			election("2022-01-01", map[string]string{"Treasurer": "Bob"}),
+			election("2023-05-01", map[string]string{"President": "Ann"}),
+		}, true},
+		{"broken streak", []pastElection{
+			election("2021-05-01", map[string]string{"President": "Ann"}),
+			election("2022-05-01", map[string]string{"President": "Bob"}),
+			election("2023-05-01", map[string]string{"President": "Ann"}),
+		}, false},
+		{"out of order", []pastElection{
+			election("2023-05-01", map[string]string{"President": "Ann"}),
+			election("2021-05-01", map[string]string{"President": "Ann"}),
+			election("2022-05-01", map[string]string{"President": "Bob"}),
+		}, false},
+	}
+
+	for _, test := range tests {
+		useConfig(t, Config{Positions: []Position{
+			{Name: "President", TermLimit: &TermLimit{MaxConsecutive: 2, Positions: []string{"President", "Co-President"}}},
+			{Name: "Treasurer"},
+		}})
+		useRoster(t, &Member{Email: "ann@example.org", Name: "Ann", Apply: true})
+		if test.history != nil {
+			saveHistory(test.history)
+		}
+
+		reason := termLimitViolation("President", findMember("ann@example.org"), "Ann")
+		if (reason != "") != test.limited {
+			t.Errorf("%s: termLimitViolation = %q, want limited %v", test.name, reason, test.limited)
+		}
+		if reason := termLimitViolation("Treasurer", findMember("ann@example.org"), "Ann"); reason != "" {
+			t.Errorf("%s: Treasurer has no term limit, but got %q", test.name, reason)
+		}
+	}
+}
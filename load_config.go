@@ -3,27 +3,65 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"os"
+	"time"
 )
 
 type DiscordConfig struct {
-	Webhook string `json:"webhook"`
-	RoleID  uint64 `json:"role_id"`
-	BoardID uint64 `json:"board_id"`
+	Webhook    string   `json:"webhook" toml:"webhook"`
+	RoleID     uint64   `json:"role_id" toml:"role_id"`
+	BoardID    uint64   `json:"board_id" toml:"board_id"`
+	BotToken   string   `json:"bot_token" toml:"bot_token"`
+	OfficerIDs []uint64 `json:"officer_ids" toml:"officer_ids"`
+
+	// bot mode
+	ApplicationID    uint64 `json:"application_id" toml:"application_id"`
+	GuildID          uint64 `json:"guild_id" toml:"guild_id"`
+	PublicKey        string `json:"public_key" toml:"public_key"`
+	InteractionsAddr string `json:"interactions_addr" toml:"interactions_addr"`
+	// overrides the Discord API base URL, e.g. to point the bot at a local stand-in
+	APIURL string `json:"api_url" toml:"api_url"`
+
+	// board role assignment; member_ids is keyed by name or email
+	MemberIDs       map[string]uint64 `json:"member_ids" toml:"member_ids"`
+	PositionRoleIDs map[string]uint64 `json:"position_role_ids" toml:"position_role_ids"`
 }
 
 type Config struct {
-	Name                   string     `json:"name"`
-	VoteDescription        string     `json:"vote_description"`
-	ApplicationDescription string     `json:"application_description"`
-	Positions              []Position `json:"positions"`
+	Name                   string `json:"name" toml:"name"`
+	VoteDescription        string `json:"vote_description" toml:"vote_description"`
+	ApplicationDescription string `json:"application_description" toml:"application_description"`
+	ApplicationDeadline    string `json:"application_deadline" toml:"application_deadline"`
+	VoteDeadline           string `json:"vote_deadline" toml:"vote_deadline"`
+	DuplicatePolicy        string `json:"duplicate_policy" toml:"duplicate_policy"`
+	Tally                  string `json:"tally" toml:"tally"`
+	// how long end-vote's encrypted snapshot of the raw responses is kept, forever if 0
+	SnapshotRetentionDays int `json:"snapshot_retention_days" toml:"snapshot_retention_days"`
+	// how many officers must certify the results before they can be published, and each officer's Ed25519 public key
+	CertificationsRequired int               `json:"certifications_required" toml:"certifications_required"`
+	OfficerKeys            map[string]string `json:"officer_keys" toml:"officer_keys"`
+	// how much of the results publish shows: "totals" (the default), "rank" for the order of the candidates without
+	// their scores, or "winners"; positions with fewer votes than min_position_votes only show the rank order
+	Disclosure       string `json:"disclosure" toml:"disclosure"`
+	MinPositionVotes uint   `json:"min_position_votes" toml:"min_position_votes"`
+	// "forms" (the default), "local" for the built-in ballot server, or "anonymous" for the ballot server storing
+	// ballots apart from who cast them
+	BallotMode string     `json:"ballot_mode" toml:"ballot_mode"`
+	BallotAddr string     `json:"ballot_addr" toml:"ballot_addr"`
+	BallotURL  string     `json:"ballot_url" toml:"ballot_url"`
+	Positions  []Position `json:"positions" toml:"positions"`
 }
 
 type Position struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name         string        `json:"name" toml:"name"`
+	Description  string        `json:"description" toml:"description"`
+	Requirements []Requirement `json:"requirements" toml:"requirements"`
+	// roster groups that may vote for this position; everyone may if empty
+	Electorate []string   `json:"electorate" toml:"electorate"`
+	TermLimit  *TermLimit `json:"term_limit" toml:"term_limit"`
 }
 
 var eligibleApplicants []string
@@ -31,42 +69,100 @@ var eligibleVoters []string
 var electionConfig Config
 var discordConfig DiscordConfig
 
-func init() {
-	appBytes, err := ioutil.ReadFile("config/applicants.txt")
+// absolute path of the election selected with --election, or "" for the working directory
+var electionDir string
+
+// the working directory the program was started in, where creds.json is
+var startDir string
+
+// zero if no deadline is configured
+var applicationDeadline time.Time
+var voteDeadline time.Time
+
+// loadConfig reads the election's config. With --election, it first changes into the election's folder, so that config/
+// and state/ are relative to it.
+func loadConfig() {
+	var err error
+	startDir, err = os.Getwd()
 	if err != nil {
 		panic(err)
 	}
-	eligibleApplicants = strings.Split(string(appBytes), "\n")
-	for i, eligibleApplicant := range eligibleApplicants {
-		eligibleApplicants[i] = strings.TrimSpace(eligibleApplicant)
+	if electionDir != "" {
+		err = os.Chdir(electionDir)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	votersBytes, err := ioutil.ReadFile("config/voters.txt")
-	if err != nil {
-		panic(err)
+	configFile, positionsFile := electionFile, electionFile
+	if _, err := os.Stat(electionFile); err == nil {
+		loadElectionFile()
+	} else {
+		configFile, positionsFile = "config/discord.json", "config/positions.json"
+		loadRoster()
+
+		configBytes, err := ioutil.ReadFile(positionsFile)
+		if err != nil {
+			panic(err)
+		}
+		err = json.Unmarshal(configBytes, &electionConfig)
+		if err != nil {
+			panic(err)
+		}
+
+		discordBytes, err := ioutil.ReadFile("config/discord.json")
+		if err != nil {
+			panic(err)
+		}
+		err = json.Unmarshal(discordBytes, &discordConfig)
+		if err != nil {
+			panic(err)
+		}
+	}
+	finishRoster()
+
+	applySecrets(configFile)
+
+	applicationDeadline = parseDeadline(electionConfig.ApplicationDeadline)
+	voteDeadline = parseDeadline(electionConfig.VoteDeadline)
+	if electionConfig.Tally != "" && electionConfig.Tally != "score" {
+		panic(fmt.Errorf("unsupported tally method %q; only \"score\" is supported", electionConfig.Tally))
+	}
+	if electionConfig.BallotMode != "" && electionConfig.BallotMode != "forms" && electionConfig.BallotMode != "local" && electionConfig.BallotMode != "anonymous" {
+		panic(fmt.Errorf("unsupported ballot mode %q; use \"forms\", \"local\" or \"anonymous\"", electionConfig.BallotMode))
 	}
-	eligibleVoters = strings.Split(string(votersBytes), "\n")
-	for i, eligibleVoter := range eligibleVoters {
-		eligibleVoters[i] = strings.TrimSpace(eligibleVoter)
+	checkRequirementDates(positionsFile)
+	if electionConfig.DuplicatePolicy != "" && electionConfig.DuplicatePolicy != "latest" && electionConfig.DuplicatePolicy != "earliest" {
+		panic(fmt.Errorf("unsupported duplicate policy %q; use \"latest\" or \"earliest\"", electionConfig.DuplicatePolicy))
 	}
+	if electionConfig.Disclosure != "" && electionConfig.Disclosure != discloseTotals && electionConfig.Disclosure != discloseRank && electionConfig.Disclosure != discloseWinners {
+		panic(fmt.Errorf("unsupported disclosure %q; use \"totals\", \"rank\" or \"winners\"", electionConfig.Disclosure))
+	}
+}
 
-	configBytes, err := ioutil.ReadFile("config/positions.json")
-	if err != nil {
-		panic(err)
+func parseDeadline(deadline string) time.Time {
+	if deadline == "" {
+		return time.Time{}
 	}
-	err = json.Unmarshal(configBytes, &electionConfig)
+	t, err := time.Parse(time.RFC3339, deadline)
 	if err != nil {
 		panic(err)
 	}
+	return t
+}
 
-	discordBytes, err := ioutil.ReadFile("config/discord.json")
-	if err != nil {
-		panic(err)
+// submittedLate reports whether a response was last submitted (or edited) after the deadline.
+// The Forms API only exposes the latest version of a response, so an edit made after the deadline
+// makes the whole response late; the version from before the deadline cannot be recovered.
+func submittedLate(lastSubmittedTime string, deadline time.Time) bool {
+	if deadline.IsZero() {
+		return false
 	}
-	err = json.Unmarshal(discordBytes, &discordConfig)
+	submitted, err := time.Parse(time.RFC3339Nano, lastSubmittedTime)
 	if err != nil {
 		panic(err)
 	}
+	return submitted.After(deadline)
 }
 
 type DiscordField struct {
@@ -93,6 +189,7 @@ func sendWebhookEmbed(text string, embed *DiscordEmbed) {
 		panic(err)
 	}
 	http.Post(discordConfig.Webhook, "application/json", bytes.NewReader(req))
+	audit("posted \"" + embed.Title + "\" to the Discord webhook")
 }
 
 func sendWebhook(text string) {
@@ -104,4 +201,5 @@ func sendWebhook(text string) {
 		panic(err)
 	}
 	http.Post(discordConfig.Webhook, "application/json", bytes.NewReader(req))
+	audit("posted a message to the Discord webhook")
 }
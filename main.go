@@ -5,10 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -81,6 +81,11 @@ func handle_start_vote(client *http.Client) {
 	applicantsByPosition := make(map[string][]string)
 	// {email, name} tuple
 	ineligibleApplicants := [][2]string{}
+	// {email, name, submission time} tuple
+	lateApplicants := [][3]string{}
+	duplicateApplicants := []duplicate{}
+	// {name, position, reason} tuple
+	ineligibleCandidacies := [][3]string{}
 	{
 		applicantResponses, err := service.Forms.Responses.List(applicationID).Do()
 		if err != nil {
@@ -90,40 +95,86 @@ func handle_start_vote(client *http.Client) {
 			fmt.Println("There are more than " + fmt.Sprint(len(applicantResponses.Responses)) + " responses! This program cannot process more than 5000 responses.")
 			os.Exit(1)
 		}
+		accepted := []*forms.FormResponse{}
 		for _, resp := range applicantResponses.Responses {
-			isEligibleApplicant := false
-			for _, eligibleApplicant := range eligibleApplicants {
-				if strings.EqualFold(eligibleApplicant, resp.RespondentEmail) {
-					isEligibleApplicant = true
-				}
-			}
-
-			applicantName := resp.Answers[nameQuestionID].TextAnswers.Answers[0].Value
-			applicantPositions := []string{}
-			for _, textAnswer := range resp.Answers[positionsQuestionID].TextAnswers.Answers {
-				applicantPositions = append(applicantPositions, textAnswer.Value)
-			}
+			applicantName := candidateName(resp.RespondentEmail, resp.Answers[nameQuestionID].TextAnswers.Answers[0].Value)
 
-			if !isEligibleApplicant {
+			if !isEligibleApplicant(resp.RespondentEmail) {
 				ineligibleApplicants = append(ineligibleApplicants, [2]string{strings.ToLower(resp.RespondentEmail), applicantName})
+			} else if submittedLate(resp.LastSubmittedTime, applicationDeadline) {
+				lateApplicants = append(lateApplicants, [3]string{strings.ToLower(resp.RespondentEmail), applicantName, resp.LastSubmittedTime})
 			} else {
-				for _, position := range applicantPositions {
-					applicantsByPosition[position] = append(applicantsByPosition[position], applicantName)
+				accepted = append(accepted, resp)
+			}
+		}
+
+		accepted, duplicateApplicants = dedupeResponses(accepted)
+		for _, resp := range accepted {
+			applicantName := candidateName(resp.RespondentEmail, resp.Answers[nameQuestionID].TextAnswers.Answers[0].Value)
+			for _, textAnswer := range resp.Answers[positionsQuestionID].TextAnswers.Answers {
+				reasons := positionIneligibility(textAnswer.Value, findMember(resp.RespondentEmail))
+				if reason := termLimitViolation(textAnswer.Value, findMember(resp.RespondentEmail), applicantName); reason != "" {
+					reasons = append(reasons, reason)
 				}
+				if len(reasons) != 0 {
+					ineligibleCandidacies = append(ineligibleCandidacies, [3]string{applicantName, textAnswer.Value, strings.Join(reasons, "; ")})
+					continue
+				}
+				applicantsByPosition[textAnswer.Value] = append(applicantsByPosition[textAnswer.Value], applicantName)
 			}
 		}
 	}
 
-	if len(ineligibleApplicants) != 0 {
-		fmt.Println("Ineligible Applicants:")
-		for _, tuple := range ineligibleApplicants {
-			fmt.Println("\t- " + tuple[1] + " <" + tuple[0] + ">")
+	if len(duplicateApplicants) != 0 {
+		fmt.Println("Applicants that applied from more than one address (only the " + duplicatePolicy() + " application is used):")
+		for _, d := range duplicateApplicants {
+			fmt.Println("\t- " + candidateName(d.emails[0], d.member.Email) + " <" + strings.Join(d.emails, ", ") + ">")
 		}
-		fmt.Print("Press [Enter] to ignore these applicants, or [Ctrl-C] to address this issue and re-run this command again later: ")
-		fmt.Scanln()
 		fmt.Println()
 	}
 
+	if len(ineligibleApplicants) != 0 || len(lateApplicants) != 0 || len(ineligibleCandidacies) != 0 {
+		if len(ineligibleApplicants) != 0 {
+			fmt.Println("Ineligible Applicants:")
+			for _, tuple := range ineligibleApplicants {
+				fmt.Println("\t- " + tuple[1] + " <" + tuple[0] + ">")
+			}
+		}
+		if len(ineligibleCandidacies) != 0 {
+			fmt.Println("Applicants that can't run for some of the positions they applied for:")
+			for _, tuple := range ineligibleCandidacies {
+				fmt.Println("\t- " + tuple[0] + " for " + tuple[1] + ": " + tuple[2])
+			}
+		}
+		if len(lateApplicants) != 0 {
+			fmt.Println("Late Applicants (submitted or edited after " + electionConfig.ApplicationDeadline + "):")
+			for _, tuple := range lateApplicants {
+				fmt.Println("\t- " + tuple[1] + " <" + tuple[0] + "> at " + tuple[2])
+			}
+		}
+		audit(fmt.Sprintf("%d ineligible, %d late applicants and %d ineligible candidacies found", len(ineligibleApplicants), len(lateApplicants), len(ineligibleCandidacies)))
+		confirm("Press [Enter] to ignore these applicants, or [Ctrl-C] to address this issue and re-run this command again later: ")
+	}
+
+	if usingLocalBallot() {
+		fmt.Println("As a reminder, do NOT share the raw results with anyone, as this will compromise the anonymity of the voting process.")
+		confirm("Press [Enter] to confirm: ")
+
+		writeCandidates(applicantsByPosition)
+		openLocalBallot()
+		// applications close only once the ballot is open, so that an aborted start-vote leaves them open
+		configureForm(client, applicationID, "application form", false, false)
+
+		sendWebhook(
+			"<@&" + fmt.Sprint(discordConfig.RoleID) + "> Voting for the " + electionConfig.Name + " has begun! Every eligible voter has been sent a personal voting link; vote before the deadline to have your vote counted. If you haven't received a link, contact an election officer.\n\n" +
+				"All votes are **anonymous**, so please vote for people that you feel are well suited for the position.\nTo maximize the value of your vote, it is recommended to **score 2 for at least one candidate per position**.\nYour link can only be used once, so double check your ballot before submitting it.",
+		)
+		sendWebhook("BTW: Remember that your election opponents, like a match opponent, may (will) be your alliance partner (team member).")
+
+		fmt.Println("You're all set!")
+		os.Exit(0)
+	}
+
 	// construct form
 	form, err := service.Forms.Create(&forms.Form{
 		Info: &forms.Info{
@@ -134,9 +185,14 @@ func handle_start_vote(client *http.Client) {
 	if err != nil {
 		panic(err)
 	}
+	audit("created ballot form " + form.FormId)
 
 	requests := []*forms.Request{}
 	for positionIdx, position := range electionConfig.Positions {
+		electorateNote := ""
+		if len(position.Electorate) != 0 {
+			electorateNote = "\n\nOnly " + strings.Join(position.Electorate, " and ") + " members may vote for this position; votes from other members will not be counted."
+		}
 		rows := []*forms.Question{}
 		for _, applicant := range applicantsByPosition[position.Name] {
 			rows = append(rows, &forms.Question{
@@ -149,7 +205,7 @@ func handle_start_vote(client *http.Client) {
 			CreateItem: &forms.CreateItemRequest{
 				Item: &forms.Item{
 					Title:       position.Name,
-					Description: position.Description + " \n\nScore each candidate from 0-2, with 2 expressing approval and 0 expressing disapproval. You do not have to fill in every row; blank rows will be treated like a 0.",
+					Description: position.Description + " \n\nScore each candidate from 0-2, with 2 expressing approval and 0 expressing disapproval. You do not have to fill in every row; blank rows will be treated like a 0." + electorateNote,
 					QuestionGroupItem: &forms.QuestionGroupItem{
 						Grid: &forms.Grid{
 							Columns: &forms.ChoiceQuestion{
@@ -188,19 +244,16 @@ func handle_start_vote(client *http.Client) {
 	}
 
 	fmt.Println()
+	configureForm(client, form.FormId, "ballot form", true, true)
 	fmt.Println("Ballot Form URL: " + "https://docs.google.com/forms/d/" + form.FormId)
 	fmt.Println("At this point, do the following on ballot form:")
-	fmt.Println("\t- Turn on 'Collect email addresses'")
 	fmt.Println("\t- Turn on 'Allow response editing'")
 	fmt.Println("\t- Turn on 'Limit to 1 response'")
-	fmt.Println("Also:")
-	fmt.Println("\t- Close the application form")
-	fmt.Print("Press [Enter] when you are done with the above: ")
-	fmt.Scanln()
-	fmt.Println()
+	confirm("Press [Enter] when you are done with the above: ")
 	fmt.Println("As a reminder, do NOT share the raw results with anyone, as this will compromise the anonymity of the voting process.")
-	fmt.Print("Press [Enter] to confirm: ")
-	fmt.Scanln()
+	confirm("Press [Enter] to confirm: ")
+
+	writeCandidates(applicantsByPosition)
 
 	f, err := os.Create("state/ballot.txt")
 	if err != nil {
@@ -208,11 +261,12 @@ func handle_start_vote(client *http.Client) {
 	}
 	defer f.Close()
 	io.WriteString(f, form.FormId)
+	configureForm(client, applicationID, "application form", false, false)
 
 	sendWebhook(
 		"<@&" + fmt.Sprint(discordConfig.RoleID) + "> Voting for the " + electionConfig.Name + " has begun! Fill out this form before the deadline to have your vote counted: " + form.ResponderUri + "\n\n" +
 			"All votes are **anonymous**, so please vote for people that you feel are well suited for the position.\nTo maximize the value of your vote, it is recommended to **score 2 for at least one candidate per position**.\nYou may edit your vote anytime before the deadline.\n\n" +
-			"Make sure you enter one of the following addresses into the \"Email\" field. **Entering an unlisted email may result in your vote being uncounted.**\n```\n" + strings.Join(eligibleVoters, "\n") + "\n```",
+			"Make sure you are signed into Google with one of the following addresses, since the form records the account you vote from. **Voting from an unlisted account may result in your vote being uncounted.**\n```\n" + strings.Join(eligibleVoters, "\n") + "\n```",
 	)
 	sendWebhook("BTW: Remember that your election opponents, like a match opponent, may (will) be your alliance partner (team member).")
 
@@ -223,10 +277,12 @@ func handle_start_vote(client *http.Client) {
 func handle_start_appliction(client *http.Client) {
 	// sanity check
 	if _, err := os.Stat("state/application.txt"); err == nil {
-		fmt.Println("`state/application.txt' already exists, meaning you've already created the application form! To reset the process, delete the `state' folder.")
+		fmt.Println("`state/application.txt' already exists, meaning you've already created the application form! To start over, archive this election with the `archive' action.")
 		os.Exit(1)
 	}
 
+	os.Mkdir("state", 0700)
+
 	service, err := forms.NewService(context.Background(), option.WithHTTPClient(client))
 	if err != nil {
 		panic(err)
@@ -241,6 +297,7 @@ func handle_start_appliction(client *http.Client) {
 	if err != nil {
 		panic(err)
 	}
+	audit("created application form " + form.FormId)
 
 	positionOptions := make([]*forms.Option, 0)
 	for _, position := range electionConfig.Positions {
@@ -297,14 +354,15 @@ func handle_start_appliction(client *http.Client) {
 	}
 
 	formEditURL := "https://docs.google.com/forms/d/" + form.FormId + "/edit"
+	fmt.Println("")
+	configureForm(client, form.FormId, "application form", true, true)
 
 	// make the election administrator make some changes
 message:
 	fmt.Println("")
 	fmt.Println("Form URL: " + formEditURL)
-	fmt.Println("At this point (since Google is kinda poopy and doesn't have a complete Forms API)\n\t - Turn on 'Collect email addresses'\n\t - Turn on 'Allow response editing'\n\t - Turn on 'Limit to 1 response'\n\t - Link a spreadsheet & make that spreadsheet publicly viewable")
-	fmt.Print("When you are done, press [Enter]: ")
-	fmt.Scanln()
+	fmt.Println("At this point (since Google is kinda poopy and doesn't have a complete Forms API)\n\t - Turn on 'Allow response editing'\n\t - Turn on 'Limit to 1 response'\n\t - Link a spreadsheet & make that spreadsheet publicly viewable")
+	confirm("When you are done, press [Enter]: ")
 
 	form, err = service.Forms.Get(form.FormId).Do()
 	if err != nil {
@@ -316,15 +374,15 @@ message:
 		time.Sleep(1 * time.Second)
 		goto message
 	}
+	audit("application form linked to spreadsheet " + form.LinkedSheetId)
 	formViewURL := form.ResponderUri
 
 	sendWebhook("<@&" + fmt.Sprint(discordConfig.RoleID) + "> Candidacy applications for the " + electionConfig.Name + " are now open! Please fill out this form before the deadline: " + formViewURL + ". Before you apply, keep in mind the requirements of the board position that you are applying for.\n\n" +
 		"This form can be edited anytime before the application deadline.\n\n" +
 		"As a reminder, **bribery and extortion are grounds for your candidacy eligibility to be revoked**. This means no personal promises, goods, money, services, etc in exchange for votes or even an implication of exchange for votes.\n\n" +
-		"Make sure you enter one of the following email addresses into the \"Email\" field. **Entering an unlisted email may result in your candidacy not being registered.**\n```" + strings.Join(eligibleApplicants, "\n") + "\n```")
+		"Make sure you are signed into Google with one of the following email addresses, since the form records the account you apply from. **Applying from an unlisted account may result in your candidacy not being registered.**\n```" + strings.Join(eligibleApplicants, "\n") + "\n```")
 	sendWebhook("Application results are updated live at https://docs.google.com/spreadsheets/d/" + form.LinkedSheetId + ".")
 
-	os.Mkdir("state", 0700)
 	f, err := os.Create("state/application.txt")
 	if err != nil {
 		panic(err)
@@ -336,12 +394,16 @@ message:
 	os.Exit(0)
 }
 
-func handleEndVote(client *http.Client) {
+func handleEndVote(client *http.Client, publishAt string) {
 	// sanity check
 	if _, err := os.Stat("state/results.txt"); err == nil {
-		fmt.Println("`state/results.txt' already exists, meaning you've already sent out the results! To restart the election, delete the state folder.")
+		fmt.Println("`state/results.txt' already exists, meaning you've already sent out the results! To start a new election, archive this one with the `archive' action.")
 		os.Exit(1)
 	}
+	if _, err := os.Stat(provisionalFile); err == nil {
+		fmt.Println("`" + provisionalFile + "' already exists, meaning the votes have already been counted. Counting them again means they have to be certified again.")
+		confirm("Press [Enter] to count the votes again, or [Ctrl-C] to cancel: ")
+	}
 
 	var ballotID string
 	{
@@ -352,108 +414,92 @@ func handleEndVote(client *http.Client) {
 		}
 		ballotID = string(ballotIDBytes)
 	}
-
-	service, err := forms.NewService(context.Background(), option.WithHTTPClient(client))
-	if err != nil {
-		panic(err)
+	if ballotID == localBallotID {
+		closeLocalBallot()
+		fmt.Println("The ballot server no longer accepts ballots, so you can stop it now.")
+	} else {
+		configureForm(client, ballotID, "ballot form", false, false)
 	}
 
-	ballot, err := service.Forms.Get(ballotID).Do()
-	if err != nil {
-		panic(err)
-	}
+	responses := fetchBallotResponses(client, ballotID)
+	writeSnapshot(client, responses)
+	count := countVotes(responses, true)
+	totalScores, unweightedScores, votedMembers := count.totalScores, count.unweightedScores, count.votedMembers
+	ineligibleVoters, lateVoters, duplicateVoters := count.ineligibleVoters, count.lateVoters, count.duplicateVoters
+	bulletin, receipts, numberEligibleVoters := count.bulletin, count.receipts, count.numberEligibleVoters
 
-	// question id => {position, candidate}
-	questionIDs := make(map[string][2]string)
-	for _, item := range ballot.Items {
-		if item.QuestionGroupItem != nil && item.QuestionGroupItem.Grid != nil && len(item.QuestionGroupItem.Questions) != 0 {
-			for _, row := range item.QuestionGroupItem.Questions {
-				questionIDs[row.QuestionId] = [2]string{item.Title, row.RowQuestion.Title}
-			}
+	if hasElectorates() {
+		fmt.Println("Turnout by position:")
+		for _, line := range positionTurnout(votedMembers) {
+			fmt.Println("\t- " + line)
 		}
+		fmt.Println()
 	}
 
-	// position => candidate => score
-	totalScores := make(map[string]map[string]uint)
-	responses, err := service.Forms.Responses.List(ballotID).Do()
-	if err != nil {
-		panic(err)
-	}
-	ineligibleVoters := []string{}
-	numberEligibleVoters := uint(0)
-	for _, resp := range responses.Responses {
-		isEligible := false
-		for _, email := range eligibleVoters {
-			if strings.EqualFold(resp.RespondentEmail, email) {
-				isEligible = true
-			}
-		}
-
-		if !isEligible {
-			ineligibleVoters = append(ineligibleVoters, strings.ToLower(resp.RespondentEmail))
-			continue
+	if len(duplicateVoters) != 0 {
+		fmt.Println("Voters that voted from more than one address (only the " + duplicatePolicy() + " ballot is counted):")
+		for _, d := range duplicateVoters {
+			fmt.Println("\t- " + d.member.Email + " <" + strings.Join(d.emails, ", ") + ">")
 		}
-		numberEligibleVoters += 1
+		fmt.Println()
+	}
 
-		for questionID, answer := range resp.Answers {
-			tuple := questionIDs[questionID]
-			position := tuple[0]
-			candidate := tuple[1]
-			score, err := strconv.ParseUint(answer.TextAnswers.Answers[0].Value, 10, 32)
-			if err != nil {
-				panic(err)
-			}
-			if totalScores[position] == nil {
-				totalScores[position] = make(map[string]uint)
+	if len(ineligibleVoters) != 0 || len(lateVoters) != 0 {
+		if len(ineligibleVoters) != 0 {
+			fmt.Println("Ineligible voters that voted:")
+			for _, voter := range ineligibleVoters {
+				fmt.Println("\t- " + voter)
 			}
-			totalScores[position][candidate] += uint(score)
 		}
-	}
-
-	if len(ineligibleVoters) != 0 {
-		fmt.Println("Ineligible voters that voted:")
-		for _, voter := range ineligibleVoters {
-			fmt.Println("\t- " + voter)
+		if len(lateVoters) != 0 {
+			fmt.Println("Voters that submitted or edited their ballot after " + electionConfig.VoteDeadline + ":")
+			for _, tuple := range lateVoters {
+				fmt.Println("\t- " + tuple[0] + " at " + tuple[1])
+			}
 		}
-		fmt.Print("Press [Enter] to ignore these votes, or [Ctrl-C] to fix the issue and re-run this command later: ")
-		fmt.Scanln()
-		fmt.Println()
-	}
-
-	sheetsService, err := sheets.NewService(context.Background(), option.WithHTTPClient(client))
-	if err != nil {
-		panic(err)
-	}
-
-	rowData := []*sheets.RowData{}
-	colData := []*sheets.DimensionProperties{}
-
-	winners := make(map[string]string)
+		audit(fmt.Sprintf("%d ineligible and %d late votes found", len(ineligibleVoters), len(lateVoters)))
+		confirm("Press [Enter] to ignore these votes, or [Ctrl-C] to fix the issue and re-run this command later: ")
+	}
+
+	results := provisionalResults{
+		BallotID:        ballotID,
+		Scores:          totalScores,
+		Rankings:        make(map[string][]rankedCandidate),
+		Weighted:        usesWeights(),
+		Winners:         make(map[string]string),
+		Tiers:           []string{},
+		Votes:           numberEligibleVoters,
+		PositionVotes:   count.positionVotes,
+		IneligibleVotes: len(ineligibleVoters),
+		LateVotes:       len(lateVoters),
+		Bulletin:        bulletin,
+		Receipts:        receipts,
+		PublishAt:       publishAt,
+	}
+	if hasElectorates() {
+		results.Turnout = positionTurnout(votedMembers)
+	}
+
+	winners := results.Winners
 	tie := ""
 	tiers := []string{}
-	for positionIdx, position := range electionConfig.Positions {
-		colData = append(colData, &sheets.DimensionProperties{PixelSize: 192})
-		colData = append(colData, &sheets.DimensionProperties{PixelSize: 32})
-
-		type candidateTuple struct {
-			name  string
-			score uint
-		}
-		candidates := []candidateTuple{}
+	for _, position := range electionConfig.Positions {
+		candidates := []rankedCandidate{}
 		for candidate, score := range totalScores[position.Name] {
-			candidates = append(candidates, candidateTuple{name: candidate, score: score})
+			candidates = append(candidates, rankedCandidate{Name: candidate, Score: score, Unweighted: unweightedScores[position.Name][candidate]})
 		}
 
 		sort.Slice(candidates, func(i, j int) bool {
-			return candidates[i].score > candidates[j].score
+			return candidates[i].Score > candidates[j].Score
 		})
+		results.Rankings[position.Name] = candidates
 
 		// winners
 		if tie == "" {
 			for i, candidate := range candidates {
 				alreadyWon := false
 				for _, winner := range winners {
-					if winner == candidate.name {
+					if winner == candidate.Name {
 						alreadyWon = true
 					}
 				}
@@ -461,20 +507,88 @@ func handleEndVote(client *http.Client) {
 					continue
 				}
 
-				if i+1 < len(candidates) && candidates[i+1].score == candidates[i].score {
+				if i+1 < len(candidates) && sameScore(candidates[i+1].Score, candidates[i].Score) {
 					tie = position.Name
 					for j := i; j < len(candidates); j++ {
-						if candidates[j].score == candidate.score {
-							tiers = append(tiers, candidates[j].name)
+						if sameScore(candidates[j].Score, candidate.Score) {
+							tiers = append(tiers, candidates[j].Name)
 						}
 					}
 					break
 				} else {
-					winners[position.Name] = candidate.name
+					winners[position.Name] = candidate.Name
 					break
 				}
 			}
 		}
+	}
+	results.Tie, results.Tiers = tie, tiers
+
+	scoresBytes, err := json.MarshalIndent(totalScores, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile("state/scores.json", scoresBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	if ballotsIdentifiable() {
+		os.Remove(bulletinFile)
+	} else {
+		writeJSON(bulletinFile, bulletin)
+	}
+	saveProvisional(results)
+
+	printProvisional(results)
+	if electionConfig.CertificationsRequired > 0 {
+		fmt.Println("These results are provisional. Before they can be published, " + fmt.Sprint(electionConfig.CertificationsRequired) + " officers must each check them and run the `certify' action. Then, run the `publish' action.")
+	} else {
+		fmt.Println("These results are provisional. Check them, then run the `publish' action to announce them.")
+	}
+	if publishAt != "" {
+		fmt.Println("Run `publish' before " + publishAt + ": it prepares the results privately and releases them then.")
+	}
+	os.Exit(0)
+}
+
+// createResultsSpreadsheet creates the results spreadsheet, which only its creator can see until it is shared.
+func createResultsSpreadsheet(client *http.Client, results provisionalResults) *sheets.Spreadsheet {
+	sheetsService, err := sheets.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		panic(err)
+	}
+
+	rowData := []*sheets.RowData{}
+	colData := []*sheets.DimensionProperties{}
+
+	// with weighted voting, the sheet shows weighted and unweighted totals side by side
+	weighted := results.Weighted
+	columnsPerPosition := 2
+	if weighted {
+		columnsPerPosition = 3
+	}
+
+	for positionIdx, position := range electionConfig.Positions {
+		colData = append(colData, &sheets.DimensionProperties{PixelSize: 192})
+		if weighted {
+			colData = append(colData, &sheets.DimensionProperties{PixelSize: 80})
+			colData = append(colData, &sheets.DimensionProperties{PixelSize: 80})
+		} else {
+			colData = append(colData, &sheets.DimensionProperties{PixelSize: 32})
+		}
+
+		candidates := results.Rankings[position.Name]
+		disclosure := positionDisclosure(results, position.Name)
+		if disclosure == discloseWinners {
+			elected := []rankedCandidate{}
+			for _, candidate := range candidates {
+				if candidate.Name == results.Winners[position.Name] {
+					elected = append(elected, candidate)
+				}
+			}
+			candidates = elected
+		}
 
 		// configure spreadsheet
 		for len(rowData) < len(candidates)+1 {
@@ -482,57 +596,110 @@ func handleEndVote(client *http.Client) {
 		}
 
 		for _, row := range rowData {
-			for len(row.Values) < len(electionConfig.Positions)*2 {
+			for len(row.Values) < len(electionConfig.Positions)*columnsPerPosition {
 				row.Values = append(row.Values, &sheets.CellData{})
 			}
 		}
 
 		positionName := position.Name
-		rowData[0].Values[positionIdx*2].UserEnteredValue = &sheets.ExtendedValue{StringValue: &positionName}
-		rowData[0].Values[positionIdx*2].UserEnteredFormat = &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}
+		rowData[0].Values[positionIdx*columnsPerPosition].UserEnteredValue = &sheets.ExtendedValue{StringValue: &positionName}
+		rowData[0].Values[positionIdx*columnsPerPosition].UserEnteredFormat = &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}
+		if weighted {
+			weightedHeader := "Weighted"
+			unweightedHeader := "Unweighted"
+			rowData[0].Values[positionIdx*columnsPerPosition+1].UserEnteredValue = &sheets.ExtendedValue{StringValue: &weightedHeader}
+			rowData[0].Values[positionIdx*columnsPerPosition+2].UserEnteredValue = &sheets.ExtendedValue{StringValue: &unweightedHeader}
+		}
 
 		for candidateIdx, candidate := range candidates {
-			candidateName := candidate.name
-			candidateScore := float64(candidate.score)
-			rowData[candidateIdx+1].Values[positionIdx*2].UserEnteredValue = &sheets.ExtendedValue{StringValue: &candidateName}
-			rowData[candidateIdx+1].Values[positionIdx*2+1].UserEnteredValue = &sheets.ExtendedValue{NumberValue: &candidateScore}
+			candidateName := candidate.Name
+			candidateScore := candidate.Score
+			rowData[candidateIdx+1].Values[positionIdx*columnsPerPosition].UserEnteredValue = &sheets.ExtendedValue{StringValue: &candidateName}
+			if disclosure != discloseTotals {
+				continue
+			}
+			rowData[candidateIdx+1].Values[positionIdx*columnsPerPosition+1].UserEnteredValue = &sheets.ExtendedValue{NumberValue: &candidateScore}
+			if weighted {
+				unweightedScore := float64(candidate.Unweighted)
+				rowData[candidateIdx+1].Values[positionIdx*columnsPerPosition+2].UserEnteredValue = &sheets.ExtendedValue{NumberValue: &unweightedScore}
+			}
 		}
 	}
 
+	resultSheets := []*sheets.Sheet{{
+		Properties: &sheets.SheetProperties{Title: "Results", GridProperties: &sheets.GridProperties{
+			RowCount:    int64(len(rowData)),
+			ColumnCount: int64(len(electionConfig.Positions) * columnsPerPosition),
+		}},
+		Data: []*sheets.GridData{{
+			RowData:        rowData,
+			ColumnMetadata: colData,
+		}},
+	}}
+	if publishesBallots(results) {
+		resultSheets = append(resultSheets, bulletinSheet(results.Bulletin, results.Scores))
+	}
+
 	sheet, err := sheetsService.Spreadsheets.Create(&sheets.Spreadsheet{
 		Properties: &sheets.SpreadsheetProperties{
 			Title: electionConfig.Name + " Results",
 		},
-		Sheets: []*sheets.Sheet{{
-			Properties: &sheets.SheetProperties{Title: "Results", GridProperties: &sheets.GridProperties{
-				RowCount:    int64(len(rowData)),
-				ColumnCount: int64(len(electionConfig.Positions)) * 2,
-			}},
-			Data: []*sheets.GridData{{
-				RowData:        rowData,
-				ColumnMetadata: colData,
-			}},
-		}},
+		Sheets: resultSheets,
 	}).Do()
 	if err != nil {
 		panic(err)
 	}
+	audit("created results spreadsheet " + sheet.SpreadsheetId)
+	return sheet
+}
 
-	fmt.Println("Ballot Form URL: https://docs.google.com/forms/d/" + ballotID + "/edit#responses")
-	fmt.Println("Spreadsheet URL: " + sheet.SpreadsheetUrl)
-	fmt.Println("At this point, make sure you do the following:")
-	fmt.Println("\t- Close ballot form")
-	fmt.Println("\t- Make spreadsheet publicly viewable")
-	fmt.Print("When you're done, press [Enter]: ")
-	fmt.Scanln()
-	fmt.Println()
+// handlePublish announces the provisional results once they have been certified.
+func handlePublish(client *http.Client) {
+	// sanity check
+	if _, err := os.Stat("state/results.txt"); err == nil {
+		fmt.Println("`state/results.txt' already exists, meaning you've already sent out the results! To start a new election, archive this one with the `archive' action.")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(provisionalFile); err != nil {
+		fmt.Println("`" + provisionalFile + "' does not exist, meaning the votes haven't been counted! Use the `end-vote' command to count them.")
+		os.Exit(1)
+	}
+	var results provisionalResults
+	readJSON(provisionalFile, &results)
+	checkCertified()
+	ballotID := results.BallotID
 
-	f, err := os.Create("state/results.txt")
-	if err != nil {
-		panic(err)
+	winners, tie, tiers := results.Winners, results.Tie, results.Tiers
+
+	// embargoed results are prepared privately, and a spreadsheet prepared earlier is reused if publish is run again
+	prepared := embargoed(results.PublishAt)
+	var spreadsheetID, spreadsheetURL string
+	if preparedBytes, err := os.ReadFile(preparedFile); err == nil {
+		prepared = true
+		spreadsheetID = string(preparedBytes)
+		spreadsheetURL = "https://docs.google.com/spreadsheets/d/" + spreadsheetID + "/edit"
+	} else {
+		sheet := createResultsSpreadsheet(client, results)
+		spreadsheetID, spreadsheetURL = sheet.SpreadsheetId, sheet.SpreadsheetUrl
+		if prepared {
+			err = os.WriteFile(preparedFile, []byte(spreadsheetID), 0600)
+			if err != nil {
+				panic(err)
+			}
+		}
 	}
-	defer f.Close()
-	io.WriteString(f, sheet.SpreadsheetId)
+
+	if ballotID != localBallotID {
+		fmt.Println("Ballot Form URL: https://docs.google.com/forms/d/" + ballotID + "/edit#responses")
+	}
+	fmt.Println("Spreadsheet URL: " + spreadsheetURL)
+	fmt.Println("At this point, make sure you do the following:")
+	if prepared {
+		fmt.Println("\t- Check the spreadsheet, but DO NOT share it: it is made publicly viewable when the results are released at " + results.PublishAt)
+	} else {
+		fmt.Println("\t- Make spreadsheet publicly viewable")
+	}
+	confirm("When you're done, press [Enter]: ")
 
 	embed := &DiscordEmbed{
 		Title: electionConfig.Name + " Results",
@@ -542,7 +709,7 @@ func handleEndVote(client *http.Client) {
 	if tie == "" {
 		embed.Description = "Congratulations to our new <@&" + fmt.Sprint(discordConfig.BoardID) + ">!"
 		for _, position := range electionConfig.Positions {
-			embed.Description += "\n - **" + winners[position.Name] + "** as " + position.Name
+			embed.Description += "\n - **" + winners[position.Name] + "**" + mention(winners[position.Name]) + " as " + position.Name
 		}
 	} else {
 		embed.Description = "There will be a runoff election for " + tie + " between **" + strings.Join(tiers, "** and **") + "**."
@@ -550,35 +717,89 @@ func handleEndVote(client *http.Client) {
 
 	embed.Fields = append(embed.Fields, &DiscordField{
 		Name:   "Results",
-		Value:  sheet.SpreadsheetUrl,
+		Value:  spreadsheetURL,
 		Inline: false,
 	})
 	embed.Fields = append(embed.Fields, &DiscordField{
 		Name:   "Votes",
-		Value:  fmt.Sprint(numberEligibleVoters),
+		Value:  fmt.Sprint(results.Votes),
 		Inline: true,
 	})
 
-	if len(ineligibleVoters) != 0 {
+	if results.IneligibleVotes != 0 {
 		embed.Fields = append(embed.Fields, &DiscordField{
 			Name:   "Ineligible Votes",
-			Value:  fmt.Sprint(len(ineligibleVoters)),
+			Value:  fmt.Sprint(results.IneligibleVotes),
+			Inline: true,
+		})
+	}
+	if len(results.Turnout) != 0 {
+		embed.Fields = append(embed.Fields, &DiscordField{
+			Name:   "Turnout",
+			Value:  strings.Join(results.Turnout, "\n"),
+			Inline: false,
+		})
+	}
+	if results.LateVotes != 0 {
+		embed.Fields = append(embed.Fields, &DiscordField{
+			Name:   "Late Votes",
+			Value:  fmt.Sprint(results.LateVotes),
 			Inline: true,
 		})
 	}
+	if note := disclosureNote(results); note != "" {
+		embed.Fields = append(embed.Fields, &DiscordField{
+			Name:   "Scores",
+			Value:  note,
+			Inline: false,
+		})
+	}
+
+	text := "<@&" + fmt.Sprint(discordConfig.RoleID) + "> Results are out! Remember that **no matter who wins, " +
+		"you're all part of the same team**."
+	if prepared {
+		previewEmbed(text, embed)
+		waitUntil(results.PublishAt)
+		shareSpreadsheet(client, spreadsheetID)
+	}
+
+	f, err := os.Create("state/results.txt")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	io.WriteString(f, spreadsheetID)
+	os.Remove(preparedFile)
+
+	if tie == "" {
+		recordWinners(winners)
+	}
 
-	sendWebhookEmbed("<@&"+fmt.Sprint(discordConfig.RoleID)+"> Results are out! Remember that **no matter who wins, "+
-		"you're all part of the same team**.", embed)
+	// anyone can later check with verify-log that the log up to here hasn't been changed
+	embed.Fields = append(embed.Fields, &DiscordField{
+		Name:   "Audit Log",
+		Value:  auditHead(),
+		Inline: false,
+	})
+
+	sendWebhookEmbed(text, embed)
+	if publishesBallots(results) {
+		sendReceipts(results.Receipts, spreadsheetURL)
+	} else if len(results.Receipts) != 0 {
+		fmt.Println("The ballots aren't published, since some scores are withheld, so no ballot receipts were sent.")
+	}
 
 	fmt.Println("As a reminder, DO NOT share the raw results (who voted for who) with anyone, as that would compromise the secrecy of the ballot.")
-	fmt.Print("Press [Enter] if you understand: ")
-	fmt.Scanln()
-	fmt.Println()
+	confirm("Press [Enter] if you understand: ")
 
 	if tie != "" {
 		fmt.Println("You're going to need to have a runoff election for " + tie + ". If there are only two candidates, it should be done using FPTP.")
 		fmt.Println("This bot will not help you with the runoff election.")
 		fmt.Println("After the runoff election, you should be able to deduce the winners based on the results spreadsheet.")
+		fmt.Println("Then use the `record-winners' action to record them for term limits.")
+	} else if discordConfig.BotToken != "" && discordConfig.GuildID != 0 && knowDiscordIDs() {
+		updateBoardRoles(winners)
+		fmt.Println("You're all set!")
 	} else {
 		fmt.Println("You're all set! Make sure you update the board roles.")
 	}
@@ -588,43 +809,125 @@ func handleEndVote(client *http.Client) {
 func main() {
 	// flag parsing
 	if len(os.Args) < 2 || os.Args[1] == "--help" || os.Args[1] == "-h" {
-		fmt.Fprintf(os.Stderr, "usage: %s [ACTION] [OPTIONS...]\n\tpossible actions: start-application, start-vote, end-vote\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [ACTION] [OPTIONS...]\n\tpossible actions: start-application, start-vote, end-vote, turnout, bot, serve-ballot, archive, history, vault SECRET, verify-log [HASH], recount, purge-snapshots, publish, record-winners, certify NAME, officer-key NAME\n\toptions:\n\t\t--election DIR: run the action on the election in DIR, which has its own config and state folders\n\t\t--dm: for turnout, send officers the list of eligible voters that haven't voted\n\t\t--snapshot: for recount, count the encrypted snapshot saved by end-vote instead of fetching the ballot\n\t\t--publish-at TIME: for end-vote, have publish hold the results back until TIME (RFC 3339, e.g. 2006-01-02T19:00:00-07:00)\n", os.Args[0])
 		os.Exit(2)
 	}
 	subcommand := os.Args[1]
-	if subcommand != "start-application" && subcommand != "start-vote" && subcommand != "end-vote" {
+	if subcommand != "start-application" && subcommand != "start-vote" && subcommand != "end-vote" && subcommand != "turnout" && subcommand != "bot" && subcommand != "archive" && subcommand != "history" && subcommand != "vault" && subcommand != "serve-ballot" && subcommand != "verify-log" && subcommand != "recount" && subcommand != "purge-snapshots" && subcommand != "publish" && subcommand != "record-winners" && subcommand != "certify" && subcommand != "officer-key" {
 		fmt.Fprintln(os.Stderr, "invalid action. type "+os.Args[0]+" --help for more information")
 		os.Exit(2)
 	}
+	dmOfficers := false
+	fromSnapshot := false
+	publishAt := ""
+	// the secret for vault, the hash for verify-log, or the officer for certify and officer-key
+	actionArg := ""
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if subcommand == "turnout" && arg == "--dm" {
+			dmOfficers = true
+		} else if subcommand == "recount" && arg == "--snapshot" {
+			fromSnapshot = true
+		} else if subcommand == "end-vote" && arg == "--publish-at" && i+1 < len(os.Args) {
+			i++
+			publishAt = os.Args[i]
+			parsePublishAt(publishAt)
+		} else if (subcommand == "vault" || subcommand == "verify-log" || subcommand == "certify" || subcommand == "officer-key") && actionArg == "" && !strings.HasPrefix(arg, "-") {
+			actionArg = arg
+		} else if arg == "--election" && i+1 < len(os.Args) {
+			i++
+			dir, err := filepath.Abs(os.Args[i])
+			if err != nil {
+				panic(err)
+			}
+			electionDir = dir
+		} else {
+			fmt.Fprintln(os.Stderr, "invalid option "+arg+". type "+os.Args[0]+" --help for more information")
+			os.Exit(2)
+		}
+	}
 
-	// credentials
-	var creds map[string]Credentials
-	file, err := ioutil.ReadFile("./creds.json")
-	if err != nil {
-		panic(err)
+	if subcommand == "vault" && actionArg == "" {
+		fmt.Fprintln(os.Stderr, "vault needs the name of the secret to store. type "+os.Args[0]+" --help for more information")
+		os.Exit(2)
+	}
+	if (subcommand == "certify" || subcommand == "officer-key") && actionArg == "" {
+		fmt.Fprintln(os.Stderr, subcommand+" needs the officer's name. type "+os.Args[0]+" --help for more information")
+		os.Exit(2)
 	}
-	json.Unmarshal(file, &creds)
 
-	var cred Credentials
-	for _, c := range creds {
-		cred = c
+	loadConfig()
+
+	// actions that only read the election or its history aren't logged, nor are the bot and ballot server, which only
+	// serve it (the actions the bot runs are logged themselves); archive starts logging once it knows there is a state
+	// folder, and audit holds entries back until there is one
+	if subcommand != "history" && subcommand != "vault" && subcommand != "verify-log" && subcommand != "archive" && subcommand != "purge-snapshots" && subcommand != "officer-key" && subcommand != "bot" && subcommand != "serve-ballot" {
+		auditAction = subcommand
+		audit(strings.TrimSpace("started " + strings.Join(os.Args[2:], " ")))
+	}
+
+	// these don't talk to Google, so they don't need to be authorized
+	switch subcommand {
+	case "bot":
+		handleBot()
+	case "archive":
+		handleArchive()
+	case "history":
+		handleHistory()
+	case "vault":
+		handleVault(actionArg)
+	case "serve-ballot":
+		handleServeBallot()
+	case "verify-log":
+		handleVerifyLog(actionArg)
+	case "certify":
+		handleCertify(actionArg)
+	case "officer-key":
+		handleOfficerKey(actionArg)
+	case "purge-snapshots":
+		handlePurgeSnapshots()
+	case "record-winners":
+		handleRecordWinners()
+	case "turnout":
+		if usingLocalBallot() {
+			handleTurnout(nil, dmOfficers)
+		}
+	}
+
+	// credentials
+	cred := loadCredentials()
+
+	scopes := []string{
+		"https://www.googleapis.com/auth/forms.body",
+		"https://www.googleapis.com/auth/forms.responses.readonly",
+		"https://www.googleapis.com/auth/spreadsheets",
+	}
+	// only embargoed results are shared by the tool itself, which needs Drive, and possibly hours after authorization,
+	// which needs a refresh token
+	authOptions := []oauth2.AuthCodeOption{}
+	if subcommand == "publish" {
+		var results provisionalResults
+		if _, err := os.Stat(provisionalFile); err == nil {
+			readJSON(provisionalFile, &results)
+		}
+		if results.PublishAt != "" {
+			scopes = append(scopes, "https://www.googleapis.com/auth/drive.file")
+			// Google only returns a refresh token with offline access, and only on the consent screen
+			authOptions = append(authOptions, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+		}
 	}
 
 	config := &oauth2.Config{
 		ClientID:     cred.ClientID,
 		ClientSecret: cred.ClientSecret,
 		RedirectURL:  "http://127.0.0.1:4444/redirect",
-		Scopes: []string{
-			"https://www.googleapis.com/auth/forms.body",
-			"https://www.googleapis.com/auth/forms.responses.readonly",
-			"https://www.googleapis.com/auth/spreadsheets",
-		},
-		Endpoint: google.Endpoint,
+		Scopes:       scopes,
+		Endpoint:     google.Endpoint,
 	}
 
 	rand.Seed(time.Now().UnixNano())
 	state := strconv.FormatUint(uint64(rand.Int63()), 36)
-	auth_url := config.AuthCodeURL(state)
+	auth_url := config.AuthCodeURL(state, authOptions...)
 
 	// serve
 	fmt.Printf("Open the following URL: %s\n", "http://127.0.0.1:4444/auth")
@@ -647,7 +950,16 @@ func main() {
 				go handle_start_vote(client)
 				io.WriteString(w, "Authorized! Return to your terminal please :)")
 			case "end-vote":
-				go handleEndVote(client)
+				go handleEndVote(client, publishAt)
+				io.WriteString(w, "Authorized! Return to your terminal please :)")
+			case "turnout":
+				go handleTurnout(client, dmOfficers)
+				io.WriteString(w, "Authorized! Return to your terminal please :)")
+			case "publish":
+				go handlePublish(client)
+				io.WriteString(w, "Authorized! Return to your terminal please :)")
+			case "recount":
+				go handleRecount(client, fromSnapshot)
 				io.WriteString(w, "Authorized! Return to your terminal please :)")
 			}
 		}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// readResultsSheet reads the weighted totals back from the Results sheet of the results spreadsheet. Positions whose
+// scores were withheld are left out.
+func readResultsSheet(client *http.Client, spreadsheetID string) map[string]map[string]float64 {
+	sheetsService, err := sheets.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		panic(err)
+	}
+	values, err := sheetsService.Spreadsheets.Values.Get(spreadsheetID, "Results").ValueRenderOption("UNFORMATTED_VALUE").Do()
+	if err != nil {
+		panic(err)
+	}
+
+	scores := make(map[string]map[string]float64)
+	if len(values.Values) == 0 {
+		return scores
+	}
+	header := values.Values[0]
+	cell := func(row []interface{}, i int) interface{} {
+		if i < len(row) {
+			return row[i]
+		}
+		return nil
+	}
+
+	// each position has a name and score column, plus an unweighted one with weighted voting
+	columnsPerPosition := 2
+	if cell(header, 1) == "Weighted" {
+		columnsPerPosition = 3
+	}
+	for col := 0; col < len(header); col += columnsPerPosition {
+		position, ok := header[col].(string)
+		if !ok || position == "" {
+			continue
+		}
+		for _, row := range values.Values[1:] {
+			candidate, ok := cell(row, col).(string)
+			if !ok || candidate == "" {
+				continue
+			}
+			score, ok := cell(row, col+1).(float64)
+			if !ok {
+				continue
+			}
+			if scores[position] == nil {
+				scores[position] = make(map[string]float64)
+			}
+			scores[position][candidate] = score
+		}
+	}
+	return scores
+}
+
+// bulletinTotals adds up the published ballots.
+func bulletinTotals(ballots []bulletinBallot) map[string]map[string]float64 {
+	totals := make(map[string]map[string]float64)
+	for _, ballot := range ballots {
+		for position, candidates := range ballot.Scores {
+			if totals[position] == nil {
+				totals[position] = make(map[string]float64)
+			}
+			for candidate, score := range candidates {
+				totals[position][candidate] += ballot.Weight * float64(score)
+			}
+		}
+	}
+	return totals
+}
+
+// diffScores lists the candidates whose score in other differs from the recount. Missing candidates count as 0, since
+// candidates nobody scored aren't listed anywhere.
+func diffScores(recounted map[string]map[string]float64, other map[string]map[string]float64, otherName string) []string {
+	keys := make(map[[2]string]bool)
+	for _, scores := range []map[string]map[string]float64{recounted, other} {
+		for position, candidates := range scores {
+			for candidate := range candidates {
+				keys[[2]string{position, candidate}] = true
+			}
+		}
+	}
+	sorted := [][2]string{}
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+
+	lines := []string{}
+	for _, key := range sorted {
+		a, b := recounted[key[0]][key[1]], other[key[0]][key[1]]
+		if !sameScore(a, b) {
+			lines = append(lines, key[0]+" / "+key[1]+": recount "+fmt.Sprint(a)+", "+otherName+" "+fmt.Sprint(b))
+		}
+	}
+	return lines
+}
+
+// handleRecount counts the ballot again, or the snapshot saved by end-vote, and compares the totals to everything
+// end-vote recorded, exiting with an error if any of them differ.
+func handleRecount(client *http.Client, fromSnapshot bool) {
+	ballotIDBytes, err := os.ReadFile("state/ballot.txt")
+	if err != nil {
+		fmt.Println("`state/ballot.txt' does not exist, meaning you haven't started the vote! Use the `start-vote' command to open the ballot.")
+		os.Exit(1)
+	}
+	if _, err := os.Stat("state/scores.json"); err != nil {
+		fmt.Println("`state/scores.json' does not exist, meaning the votes haven't been counted yet, so there is nothing to recount.")
+		os.Exit(1)
+	}
+
+	var responses ballotResponses
+	if fromSnapshot {
+		responses = readSnapshot().Ballot
+	} else {
+		responses = fetchBallotResponses(client, string(ballotIDBytes))
+	}
+	count := countVotes(responses, false)
+
+	var recorded map[string]map[string]float64
+	readJSON("state/scores.json", &recorded)
+	type source struct {
+		name   string
+		scores map[string]map[string]float64
+		// only the positions listed in scores are compared
+		partial bool
+	}
+	sources := []source{{"`state/scores.json'", recorded, false}}
+	// the spreadsheet only exists once the results are published
+	if resultsIDBytes, err := os.ReadFile("state/results.txt"); err == nil {
+		sheetScores := readResultsSheet(client, string(resultsIDBytes))
+		for _, position := range electionConfig.Positions {
+			if _, ok := sheetScores[position.Name]; !ok {
+				fmt.Println("The results spreadsheet doesn't list scores for " + position.Name + ", so they weren't compared.")
+			}
+		}
+		sources = append(sources, source{"the results spreadsheet", sheetScores, true})
+	}
+	// elections ended before ballots were published don't have it
+	if _, err := os.Stat(bulletinFile); err == nil {
+		var bulletin []bulletinBallot
+		readJSON(bulletinFile, &bulletin)
+		sources = append(sources, source{"the published ballots", bulletinTotals(bulletin), false})
+	}
+
+	discrepancies := 0
+	for _, source := range sources {
+		recounted := count.totalScores
+		if source.partial {
+			recounted = make(map[string]map[string]float64)
+			for position := range source.scores {
+				recounted[position] = count.totalScores[position]
+			}
+		}
+		lines := diffScores(recounted, source.scores, source.name)
+		if len(lines) == 0 {
+			fmt.Println("No differences from " + source.name + ".")
+			continue
+		}
+		fmt.Println("Differences from " + source.name + ":")
+		for _, line := range lines {
+			fmt.Println("\t- " + line)
+		}
+		discrepancies += len(lines)
+	}
+	fmt.Println("Recounted " + fmt.Sprint(count.numberEligibleVoters) + " ballots.")
+
+	if discrepancies != 0 {
+		audit(fmt.Sprintf("recount found %d discrepancies", discrepancies))
+		os.Exit(1)
+	}
+	audit("recount matches the results")
+	os.Exit(0)
+}
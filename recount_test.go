@@ -0,0 +1,24 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffScores(t *testing.T) {
+	recounted := map[string]map[string]float64{"P": {"Ann": 3, "Bob": 0.1 + 0.2}, "VP": {"Cat": 2}}
+	tests := []struct {
+		other map[string]map[string]float64
+		want  []string
+	}{
+		{map[string]map[string]float64{"P": {"Ann": 3, "Bob": 0.3}, "VP": {"Cat": 2}}, []string{}},
+		{map[string]map[string]float64{"P": {"Ann": 2, "Bob": 0.3}, "VP": {"Cat": 2}}, []string{"P / Ann: recount 3, end-vote 2"}},
+		{map[string]map[string]float64{"P": {"Ann": 3, "Bob": 0.3}}, []string{"VP / Cat: recount 2, end-vote 0"}},
+		{map[string]map[string]float64{"P": {"Ann": 3, "Bob": 0.3, "Dan": 1}, "VP": {"Cat": 2}}, []string{"P / Dan: recount 0, end-vote 1"}},
+	}
+	for _, test := range tests {
+		if got := diffScores(recounted, test.other, "end-vote"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("diffScores(%v) = %q, want %q", test.other, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Requirement restricts who may run for a position, based on an attribute in the roster. All of the conditions that
+// are set must hold.
+type Requirement struct {
+	Attribute string `json:"attribute" toml:"attribute"`
+	// the attribute must be one of these values
+	In []string `json:"in" toml:"in"`
+	// the attribute must be a number within these bounds
+	Min *float64 `json:"min" toml:"min"`
+	Max *float64 `json:"max" toml:"max"`
+	// the attribute must be a date (YYYY-MM-DD) before / after this date
+	Before string `json:"before" toml:"before"`
+	After  string `json:"after" toml:"after"`
+	// shown to the officer when an applicant doesn't meet the requirement
+	Reason string `json:"reason" toml:"reason"`
+}
+
+const dateLayout = "2006-01-02"
+
+// check returns why the member doesn't meet the requirement, or "" if they do.
+func (r *Requirement) check(member *Member) string {
+	reason := r.Reason
+	if reason == "" {
+		reason = "doesn't meet the `" + r.Attribute + "' requirement"
+	}
+
+	value, ok := "", false
+	if member != nil {
+		value, ok = member.Attributes[r.Attribute]
+	}
+	if !ok || value == "" {
+		return reason + " (no `" + r.Attribute + "' in roster)"
+	}
+
+	if len(r.In) != 0 {
+		found := false
+		for _, allowed := range r.In {
+			if strings.EqualFold(allowed, value) {
+				found = true
+			}
+		}
+		if !found {
+			return reason + " (" + r.Attribute + " is " + value + ")"
+		}
+	}
+
+	if r.Min != nil || r.Max != nil {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return reason + " (" + r.Attribute + " is not a number: " + value + ")"
+		}
+		if (r.Min != nil && number < *r.Min) || (r.Max != nil && number > *r.Max) {
+			return reason + " (" + r.Attribute + " is " + value + ")"
+		}
+	}
+
+	if r.Before != "" || r.After != "" {
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return reason + " (" + r.Attribute + " is not a date: " + value + ")"
+		}
+		if r.Before != "" && !date.Before(parseDate(r.Before)) {
+			return reason + " (" + r.Attribute + " is " + value + ")"
+		}
+		if r.After != "" && !date.After(parseDate(r.After)) {
+			return reason + " (" + r.Attribute + " is " + value + ")"
+		}
+	}
+
+	return ""
+}
+
+// parseDate parses a requirement's date, which checkRequirementDates has already validated.
+func parseDate(date string) time.Time {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// checkRequirementDates makes sure the dates in the requirements are valid, so that a typo is caught when the config is
+// loaded instead of when applicants are checked.
+func checkRequirementDates(configFile string) {
+	for _, position := range electionConfig.Positions {
+		for _, requirement := range position.Requirements {
+			for _, date := range []string{requirement.Before, requirement.After} {
+				if date == "" {
+					continue
+				}
+				if _, err := time.Parse(dateLayout, date); err != nil {
+					panic(fmt.Errorf("invalid date %q in the %s requirements in %s: %w", date, position.Name, configFile, err))
+				}
+			}
+		}
+	}
+}
+
+// positionIneligibility returns the reasons a member can't run for a position, or nil if they can.
+func positionIneligibility(positionName string, member *Member) []string {
+	position := findPosition(positionName)
+	if position == nil {
+		return nil
+	}
+	reasons := []string{}
+	for i := range position.Requirements {
+		if reason := position.Requirements[i].check(member); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return reasons
+}
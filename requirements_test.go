@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestRequirementCheck(t *testing.T) {
+	four, twelve := 4.0, 12.0
+	member := &Member{Attributes: map[string]string{"grade": "11", "team": "FTC", "joined": "2022-09-01"}}
+	tests := []struct {
+		requirement Requirement
+		ok          bool
+	}{
+		{Requirement{Attribute: "team", In: []string{"ftc", "FRC"}}, true},
+		{Requirement{Attribute: "team", In: []string{"FRC"}}, false},
+		{Requirement{Attribute: "grade", Min: &four, Max: &twelve}, true},
+		{Requirement{Attribute: "grade", Max: &four}, false},
+		{Requirement{Attribute: "team", Min: &four}, false},
+		{Requirement{Attribute: "joined", Before: "2023-01-01"}, true},
+		{Requirement{Attribute: "joined", After: "2023-01-01"}, false},
+		{Requirement{Attribute: "joined", Before: "2022-09-01"}, false},
+		{Requirement{Attribute: "team", Before: "2023-01-01"}, false},
+		{Requirement{Attribute: "missing"}, false},
+	}
+	for _, test := range tests {
+		if reason := test.requirement.check(member); (reason == "") != test.ok {
+			t.Errorf("%+v: check = %q, want ok %v", test.requirement, reason, test.ok)
+		}
+	}
+	if reason := (&Requirement{Attribute: "grade"}).check(nil); reason == "" {
+		t.Error("a member missing from the roster meets a requirement")
+	}
+}
+
+func TestCheckRequirementDates(t *testing.T) {
+	tests := []struct {
+		date string
+		ok   bool
+	}{
+		{"", true},
+		{"2024-02-29", true},
+		{"2024-13-01", false},
+		{"02/01/2024", false},
+	}
+	for _, test := range tests {
+		useConfig(t, Config{Positions: []Position{{Name: "P", Requirements: []Requirement{{Attribute: "joined", After: test.date}}}}})
+		panicked := func() (panicked bool) {
+			defer func() { panicked = recover() != nil }()
+			checkRequirementDates(electionFile)
+			return false
+		}()
+		if panicked == test.ok {
+			t.Errorf("date %q: rejected %v, want %v", test.date, panicked, !test.ok)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Member struct {
+	Email     string `json:"email" toml:"email"`
+	Name      string `json:"name" toml:"name"`
+	DiscordID uint64 `json:"discord_id" toml:"discord_id"`
+	// whether the member may vote / run for a position
+	Vote  bool `json:"vote" toml:"vote"`
+	Apply bool `json:"apply" toml:"apply"`
+	// e.g. "build" or "programming", used to restrict who may vote for a position
+	Groups []string `json:"groups" toml:"groups"`
+	// how much the member's ballot counts for, 1 if unset
+	Weight *float64 `json:"weight" toml:"weight"`
+	// free-form membership attributes, e.g. "grade" or "joined"
+	Attributes map[string]string `json:"attributes" toml:"attributes"`
+}
+
+var roster []*Member
+
+// loadRoster reads config/roster.json or config/roster.csv. If neither exists, the roster is built from voters.txt and
+// applicants.txt, so members only have an email address.
+func loadRoster() {
+	if _, err := os.Stat("config/roster.json"); err == nil {
+		roster = readRosterFile("config/roster.json")
+	} else if _, err := os.Stat("config/roster.csv"); err == nil {
+		roster = readRosterFile("config/roster.csv")
+	} else {
+		roster = rosterFromLists(readList("config/voters.txt"), readList("config/applicants.txt"))
+	}
+}
+
+// readRosterFile reads a JSON or CSV roster, depending on the file extension.
+func readRosterFile(path string) []*Member {
+	if strings.HasSuffix(path, ".csv") {
+		rosterFile, err := os.Open(path)
+		if err != nil {
+			panic(err)
+		}
+		defer rosterFile.Close()
+		records, err := csv.NewReader(rosterFile).ReadAll()
+		if err != nil {
+			panic(err)
+		}
+		return parseRosterCSV(records)
+	}
+
+	rosterBytes, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	members := []*Member{}
+	err = json.Unmarshal(rosterBytes, &members)
+	if err != nil {
+		panic(err)
+	}
+	return members
+}
+
+// readList reads a list of email addresses, one per line.
+func readList(path string) []string {
+	listBytes, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return strings.Split(string(listBytes), "\n")
+}
+
+// rosterFromLists builds a roster from lists of voter and applicant email addresses.
+func rosterFromLists(voters []string, applicants []string) []*Member {
+	members := []*Member{}
+	byEmail := make(map[string]*Member)
+	add := func(email string) *Member {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			return &Member{}
+		}
+		member, ok := byEmail[strings.ToLower(email)]
+		if !ok {
+			member = &Member{Email: email}
+			byEmail[strings.ToLower(email)] = member
+			members = append(members, member)
+		}
+		return member
+	}
+	for _, email := range voters {
+		add(email).Vote = true
+	}
+	for _, email := range applicants {
+		add(email).Apply = true
+	}
+	return members
+}
+
+// finishRoster sets up everything that depends on the roster once it's loaded.
+func finishRoster() {
+	loadAliases()
+
+	eligibleVoters = []string{}
+	eligibleApplicants = []string{}
+	for _, member := range roster {
+		member.Email = strings.TrimSpace(member.Email)
+		if weight := member.weight(); weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			panic(fmt.Errorf("roster member %q has weight %v; weights must be a number of at least 0", member.Email, weight))
+		}
+		if member.Vote {
+			eligibleVoters = append(eligibleVoters, member.Email)
+		}
+		if member.Apply {
+			eligibleApplicants = append(eligibleApplicants, member.Email)
+		}
+	}
+}
+
+// parseRosterCSV reads a roster with a header row. The email, name, discord_id, vote, apply, weight and groups
+// (separated by semicolons) columns fill in the corresponding fields; every other column is an attribute.
+func parseRosterCSV(records [][]string) []*Member {
+	if len(records) == 0 {
+		return nil
+	}
+	header := records[0]
+	members := []*Member{}
+	for line, record := range records[1:] {
+		member := &Member{Attributes: make(map[string]string)}
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			name := strings.TrimSpace(strings.ToLower(column))
+			switch name {
+			case "email":
+				member.Email = value
+			case "name":
+				member.Name = value
+			case "discord_id":
+				if value != "" {
+					id, err := strconv.ParseUint(value, 10, 64)
+					if err != nil {
+						panic(fmt.Errorf("roster line %d: %w", line+2, err))
+					}
+					member.DiscordID = id
+				}
+			case "weight":
+				if value != "" {
+					weight, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						panic(fmt.Errorf("roster line %d: %w", line+2, err))
+					}
+					member.Weight = &weight
+				}
+			case "groups":
+				for _, group := range strings.Split(value, ";") {
+					if strings.TrimSpace(group) != "" {
+						member.Groups = append(member.Groups, strings.TrimSpace(group))
+					}
+				}
+			case "vote", "apply":
+				allowed := value != "" && value != "0" && !strings.EqualFold(value, "false") && !strings.EqualFold(value, "no")
+				if name == "vote" {
+					member.Vote = allowed
+				} else {
+					member.Apply = allowed
+				}
+			default:
+				member.Attributes[strings.TrimSpace(column)] = value
+			}
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func (m *Member) weight() float64 {
+	if m == nil || m.Weight == nil {
+		return 1
+	}
+	return *m.Weight
+}
+
+// usesWeights reports whether any ballot counts for something other than 1.
+func usesWeights() bool {
+	for _, member := range roster {
+		if member.weight() != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// sameScore compares weighted totals, which may carry floating point error.
+func sameScore(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// findMember looks up a roster entry by email address, after normalization and alias resolution.
+func findMember(email string) *Member {
+	email = normalizeEmail(email)
+	if alias, ok := aliases[email]; ok {
+		email = normalizeEmail(alias)
+	}
+	for _, member := range roster {
+		if member.Email != "" && normalizeEmail(member.Email) == email {
+			return member
+		}
+	}
+	return nil
+}
+
+// findMemberByName looks up a roster entry by display name.
+func findMemberByName(name string) *Member {
+	for _, member := range roster {
+		if member.Name != "" && strings.EqualFold(member.Name, strings.TrimSpace(name)) {
+			return member
+		}
+	}
+	return nil
+}
+
+func isEligibleVoter(email string) bool {
+	member := findMember(email)
+	return member != nil && member.Vote
+}
+
+func isEligibleApplicant(email string) bool {
+	member := findMember(email)
+	return member != nil && member.Apply
+}
+
+// candidateName is the name shown on the ballot: the roster name if there is one, otherwise the name typed into the
+// application form.
+func candidateName(email string, typedName string) string {
+	if member := findMember(email); member != nil && member.Name != "" {
+		return member.Name
+	}
+	return strings.TrimSpace(typedName)
+}
+
+// mention @mentions a member on Discord if their Discord ID is known.
+func mention(name string) string {
+	if member := findMemberByName(name); member != nil && member.DiscordID != 0 {
+		return " (<@" + fmt.Sprint(member.DiscordID) + ">)"
+	}
+	return ""
+}
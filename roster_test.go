@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+// inTempDir runs the rest of the test in an empty folder, as if it were the election's folder.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+}
+
+// useRoster replaces the roster, as loadConfig would.
+func useRoster(t *testing.T, members ...*Member) {
+	t.Helper()
+	inTempDir(t)
+	roster = members
+	finishRoster()
+}
+
+func TestParseRosterCSV(t *testing.T) {
+	members := parseRosterCSV([][]string{
+		{" Email ", "Name", " VOTE ", "Apply", "weight", "groups", "discord_id", "Grade"},
+		{"a@example.org", "Ada", "yes", "no", "0.5", "build; programming", "42", "11"},
+		{"b@example.org", "Bo", "false", "1", "", "", "", ""},
+	})
+	if len(members) != 2 {
+		t.Fatalf("got %d members, want 2", len(members))
+	}
+
+	a, b := members[0], members[1]
+	if a.Email != "a@example.org" || a.Name != "Ada" || a.DiscordID != 42 {
+		t.Errorf("first member is %+v", a)
+	}
+	if !a.Vote || a.Apply {
+		t.Errorf("first member: vote %v, apply %v; want true, false", a.Vote, a.Apply)
+	}
+	if b.Vote || !b.Apply {
+		t.Errorf("second member: vote %v, apply %v; want false, true", b.Vote, b.Apply)
+	}
+	if a.weight() != 0.5 || b.weight() != 1 {
+		t.Errorf("weights are %v and %v, want 0.5 and 1", a.weight(), b.weight())
+	}
+	if len(a.Groups) != 2 || a.Groups[0] != "build" || a.Groups[1] != "programming" {
+		t.Errorf("groups are %q", a.Groups)
+	}
+	if a.Attributes["Grade"] != "11" {
+		t.Errorf("attributes are %v", a.Attributes)
+	}
+}
+
+func TestRosterWeights(t *testing.T) {
+	weight := func(w float64) *float64 { return &w }
+	tests := []struct {
+		weight *float64
+		ok     bool
+	}{
+		{nil, true},
+		{weight(0), true},
+		{weight(0.5), true},
+		{weight(-1), false},
+		{weight(math.NaN()), false},
+		{weight(math.Inf(1)), false},
+	}
+	for _, test := range tests {
+		panicked := func() (panicked bool) {
+			defer func() { panicked = recover() != nil }()
+			useRoster(t, &Member{Email: "a@example.org", Vote: true, Weight: test.weight})
+			return false
+		}()
+		if panicked == test.ok {
+			t.Errorf("weight %v: rejected %v, want %v", (&Member{Weight: test.weight}).weight(), panicked, !test.ok)
+		}
+	}
+}
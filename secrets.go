@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Secrets are looked up in this order: environment variables, the vault, then the config files and creds.json, which
+// must only be readable by their owner.
+
+// kept next to creds.json, so it is shared by all elections
+const vaultFile = "secrets.vault"
+
+// vault keys and the environment variables that override them
+var secretEnvs = map[string]string{
+	"webhook":              "ELECTION_DISCORD_WEBHOOK",
+	"bot_token":            "ELECTION_DISCORD_BOT_TOKEN",
+	"google_client_id":     "ELECTION_GOOGLE_CLIENT_ID",
+	"google_client_secret": "ELECTION_GOOGLE_CLIENT_SECRET",
+}
+
+type sealedVault struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Box   []byte `json:"box"`
+}
+
+// unlocked vault contents, nil if there is no vault
+var vault map[string]string
+var vaultPassphrase string
+
+// checkPermissions refuses to continue if a file holding secrets can be read by other users.
+func checkPermissions(path string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		fmt.Println("`" + path + "' contains secrets but can be accessed by other users. Run `chmod 600 " + path + "' and try again.")
+		os.Exit(1)
+	}
+}
+
+// readPassphrase reads a passphrase from the environment variable env, or asks for it without echoing.
+func readPassphrase(env string, prompt string) string {
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return passphrase
+	}
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		panic(err)
+	}
+	return string(passphrase)
+}
+
+func vaultKey(passphrase string, salt []byte) *[32]byte {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		panic(err)
+	}
+	var k [32]byte
+	copy(k[:], key)
+	return &k
+}
+
+// openVault decrypts the vault, returning nil if there isn't one.
+func openVault(passphrase string) map[string]string {
+	path := filepath.Join(startDir, vaultFile)
+	sealedBytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		panic(err)
+	}
+	checkPermissions(path)
+
+	var sealed sealedVault
+	err = json.Unmarshal(sealedBytes, &sealed)
+	if err != nil || len(sealed.Nonce) != 24 {
+		fmt.Println("`" + path + "' is not a valid vault.")
+		os.Exit(1)
+	}
+	opened := sealed.open(passphrase, path)
+
+	secrets := make(map[string]string)
+	err = json.Unmarshal(opened, &secrets)
+	if err != nil {
+		panic(err)
+	}
+	return secrets
+}
+
+// seal encrypts plain with a key derived from the passphrase.
+func seal(plain []byte, passphrase string) sealedVault {
+	sealed := sealedVault{Salt: make([]byte, 16), Nonce: make([]byte, 24)}
+	_, err := rand.Read(sealed.Salt)
+	if err != nil {
+		panic(err)
+	}
+	_, err = rand.Read(sealed.Nonce)
+	if err != nil {
+		panic(err)
+	}
+	var nonce [24]byte
+	copy(nonce[:], sealed.Nonce)
+	sealed.Box = secretbox.Seal(nil, plain, &nonce, vaultKey(passphrase, sealed.Salt))
+	return sealed
+}
+
+// open decrypts what seal encrypted, exiting if the passphrase is wrong. path is the file it was read from.
+func (sealed sealedVault) open(passphrase string, path string) []byte {
+	var nonce [24]byte
+	copy(nonce[:], sealed.Nonce)
+	opened, ok := secretbox.Open(nil, sealed.Box, &nonce, vaultKey(passphrase, sealed.Salt))
+	if !ok {
+		fmt.Println("Wrong passphrase for `" + path + "'.")
+		os.Exit(1)
+	}
+	return opened
+}
+
+func saveVault(secrets map[string]string, passphrase string) {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		panic(err)
+	}
+	sealedBytes, err := json.Marshal(seal(plain, passphrase))
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(filepath.Join(startDir, vaultFile), sealedBytes, 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// secret looks up a secret in the environment and then the vault.
+func secret(key string) string {
+	if value := os.Getenv(secretEnvs[key]); value != "" {
+		return value
+	}
+	return vault[key]
+}
+
+// applySecrets fills in the Discord secrets once the config is loaded. configFile is the file the Discord config was
+// read from.
+func applySecrets(configFile string) {
+	if discordConfig.Webhook != "" || discordConfig.BotToken != "" {
+		checkPermissions(configFile)
+	}
+
+	if _, err := os.Stat(filepath.Join(startDir, vaultFile)); err == nil {
+		vaultPassphrase = readPassphrase("ELECTION_VAULT_PASSPHRASE", "Vault passphrase: ")
+		vault = openVault(vaultPassphrase)
+	}
+
+	if webhook := secret("webhook"); webhook != "" {
+		discordConfig.Webhook = webhook
+	}
+	if botToken := secret("bot_token"); botToken != "" {
+		discordConfig.BotToken = botToken
+	}
+}
+
+// loadCredentials finds the Google OAuth client. creds.json is the file downloaded from the Google Cloud console, which
+// holds one client under a key such as "installed"; ELECTION_GOOGLE_CLIENT picks one if there are several.
+func loadCredentials() Credentials {
+	if clientID, clientSecret := secret("google_client_id"), secret("google_client_secret"); clientID != "" && clientSecret != "" {
+		return Credentials{ClientID: clientID, ClientSecret: clientSecret}
+	}
+
+	path := filepath.Join(startDir, "creds.json")
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println("Couldn't read the Google OAuth client from `" + path + "' (" + err.Error() + "). Download it from the Google Cloud console, or set ELECTION_GOOGLE_CLIENT_ID and ELECTION_GOOGLE_CLIENT_SECRET.")
+		os.Exit(1)
+	}
+	checkPermissions(path)
+
+	var creds map[string]Credentials
+	err = json.Unmarshal(file, &creds)
+	if err != nil {
+		fmt.Println("`" + path + "' is not a valid OAuth client file: " + err.Error())
+		os.Exit(1)
+	}
+
+	var cred Credentials
+	if name := os.Getenv("ELECTION_GOOGLE_CLIENT"); name != "" {
+		c, ok := creds[name]
+		if !ok {
+			fmt.Println("`" + path + "' has no client named `" + name + "'.")
+			os.Exit(1)
+		}
+		cred = c
+	} else if len(creds) == 1 {
+		for _, c := range creds {
+			cred = c
+		}
+	} else {
+		names := []string{}
+		for name := range creds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			fmt.Println("`" + path + "' doesn't contain an OAuth client.")
+		} else {
+			fmt.Println("`" + path + "' contains more than one OAuth client (" + strings.Join(names, ", ") + "). Set ELECTION_GOOGLE_CLIENT to the one to use.")
+		}
+		os.Exit(1)
+	}
+
+	if cred.ClientID == "" || cred.ClientSecret == "" {
+		fmt.Println("The OAuth client in `" + path + "' is missing its client_id or client_secret.")
+		os.Exit(1)
+	}
+	return cred
+}
+
+// handleVault stores a secret in the vault, creating it if needed.
+func handleVault(key string) {
+	if _, ok := secretEnvs[key]; !ok {
+		keys := []string{}
+		for k := range secretEnvs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Println("Unknown secret `" + key + "'. Possible secrets: " + strings.Join(keys, ", "))
+		os.Exit(2)
+	}
+
+	// loadConfig has already unlocked the vault if there is one
+	secrets, passphrase := vault, vaultPassphrase
+	if secrets == nil {
+		passphrase = readPassphrase("ELECTION_VAULT_PASSPHRASE", "New vault passphrase: ")
+		if os.Getenv("ELECTION_VAULT_PASSPHRASE") == "" && readPassphrase("ELECTION_VAULT_PASSPHRASE", "Repeat passphrase: ") != passphrase {
+			fmt.Println("The passphrases don't match.")
+			os.Exit(1)
+		}
+		secrets = make(map[string]string)
+	}
+
+	fmt.Print("Value for `" + key + "': ")
+	value, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		panic(err)
+	}
+	secrets[key] = strings.TrimSpace(string(value))
+	saveVault(secrets, passphrase)
+
+	fmt.Println("Saved `" + key + "' to `" + filepath.Join(startDir, vaultFile) + "'.")
+	os.Exit(0)
+}
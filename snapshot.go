@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/forms/v1"
+	"google.golang.org/api/option"
+)
+
+// end-vote keeps an encrypted copy of the raw application and ballot responses, so that recounts and audits don't depend
+// on the forms still existing. It is encrypted with a passphrase chosen by the officer, and purge-snapshots deletes it
+// once snapshot_retention_days have passed.
+
+const snapshotFile = "state/snapshot.sealed"
+
+type rawSnapshot struct {
+	Applications []*forms.FormResponse `json:"applications"`
+	Ballot       ballotResponses       `json:"ballot"`
+}
+
+type sealedSnapshot struct {
+	// left unencrypted, so expired snapshots can be found without the passphrase
+	Created string `json:"created"`
+	sealedVault
+}
+
+// writeSnapshot fetches the application responses and encrypts them along with the ballot responses.
+func writeSnapshot(client *http.Client, ballot ballotResponses) {
+	applications := []*forms.FormResponse{}
+	if applicationIDBytes, err := os.ReadFile("state/application.txt"); err == nil {
+		service, err := forms.NewService(context.Background(), option.WithHTTPClient(client))
+		if err != nil {
+			panic(err)
+		}
+		formResponses, err := service.Forms.Responses.List(string(applicationIDBytes)).Do()
+		if err != nil {
+			panic(err)
+		}
+		applications = formResponses.Responses
+	}
+
+	plain, err := json.Marshal(rawSnapshot{Applications: applications, Ballot: ballot})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("An encrypted copy of the raw responses will be saved to `" + snapshotFile + "' for recounts and audits.")
+	passphrase := readPassphrase("ELECTION_SNAPSHOT_PASSPHRASE", "Passphrase to encrypt it with: ")
+	for os.Getenv("ELECTION_SNAPSHOT_PASSPHRASE") == "" && readPassphrase("ELECTION_SNAPSHOT_PASSPHRASE", "Repeat passphrase: ") != passphrase {
+		fmt.Println("The passphrases don't match.")
+		passphrase = readPassphrase("ELECTION_SNAPSHOT_PASSPHRASE", "Passphrase to encrypt it with: ")
+	}
+
+	writeJSON(snapshotFile, sealedSnapshot{Created: time.Now().UTC().Format(time.RFC3339), sealedVault: seal(plain, passphrase)})
+	audit(fmt.Sprintf("saved an encrypted snapshot of %d applications and %d ballots", len(applications), len(ballot.Responses)))
+	fmt.Println()
+}
+
+// readSnapshot decrypts the snapshot written by end-vote.
+func readSnapshot() rawSnapshot {
+	if _, err := os.Stat(snapshotFile); err != nil {
+		fmt.Println("`" + snapshotFile + "' does not exist. It is written by `end-vote', and deleted by `purge-snapshots' once it expires.")
+		os.Exit(1)
+	}
+	var sealed sealedSnapshot
+	readJSON(snapshotFile, &sealed)
+	passphrase := readPassphrase("ELECTION_SNAPSHOT_PASSPHRASE", "Snapshot passphrase: ")
+
+	var snapshot rawSnapshot
+	err := json.Unmarshal(sealed.open(passphrase, snapshotFile), &snapshot)
+	if err != nil {
+		panic(err)
+	}
+	return snapshot
+}
+
+// shred overwrites a file with random bytes before deleting it. Copy-on-write filesystems and SSDs may still keep the
+// old contents around, which is why snapshots are encrypted in the first place.
+func shred(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		panic(err)
+	}
+	noise := make([]byte, info.Size())
+	_, err = rand.Read(noise)
+	if err != nil {
+		panic(err)
+	}
+	_, err = f.WriteAt(noise, 0)
+	if err != nil {
+		panic(err)
+	}
+	err = f.Sync()
+	if err != nil {
+		panic(err)
+	}
+	f.Close()
+	err = os.Remove(path)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// handlePurgeSnapshots deletes the snapshots of this election and its archived ones that are older than the retention
+// period.
+func handlePurgeSnapshots() {
+	if electionConfig.SnapshotRetentionDays <= 0 {
+		fmt.Println("`snapshot_retention_days' isn't set, so snapshots are kept until you delete them.")
+		os.Exit(0)
+	}
+	retention := time.Duration(electionConfig.SnapshotRetentionDays) * 24 * time.Hour
+	// only logged if there is an election in progress to log to
+	if _, err := os.Stat("state"); err == nil {
+		auditAction = "purge-snapshots"
+	}
+
+	paths, err := filepath.Glob(filepath.Join("archive", "*", snapshotFile))
+	if err != nil {
+		panic(err)
+	}
+	if _, err := os.Stat(snapshotFile); err == nil {
+		paths = append(paths, snapshotFile)
+	}
+
+	expired := []string{}
+	for _, path := range paths {
+		var sealed sealedSnapshot
+		readJSON(path, &sealed)
+		created, err := time.Parse(time.RFC3339, sealed.Created)
+		if err != nil {
+			fmt.Println("`" + path + "' has an invalid creation time, so it was skipped.")
+			continue
+		}
+		if time.Since(created) > retention {
+			expired = append(expired, path)
+		}
+	}
+	if len(expired) == 0 {
+		fmt.Println("No snapshots are older than " + fmt.Sprint(electionConfig.SnapshotRetentionDays) + " days.")
+		os.Exit(0)
+	}
+
+	fmt.Println("Snapshots older than " + fmt.Sprint(electionConfig.SnapshotRetentionDays) + " days:")
+	for _, path := range expired {
+		fmt.Println("\t- " + path)
+	}
+	confirm("Press [Enter] to delete them for good, or [Ctrl-C] to keep them: ")
+	for _, path := range expired {
+		shred(path)
+		audit("deleted snapshot " + path)
+	}
+	fmt.Println("Deleted " + fmt.Sprint(len(expired)) + " snapshots.")
+	os.Exit(0)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/forms/v1"
+	"google.golang.org/api/option"
+)
+
+// ballotResponses are the raw responses to the ballot, from the form or the ballot server.
+type ballotResponses struct {
+	// question id => {position, candidate}
+	QuestionIDs map[string][2]string  `json:"question_ids"`
+	Responses   []*forms.FormResponse `json:"responses"`
+	// response ID => ballot, for ballots cast on the ballot server
+	LocalBallots map[string]*localBallot `json:"local_ballots"`
+	// the weighted totals of anonymous ballots, which they don't store individually
+	AnonymousTotals *anonymousTotals `json:"anonymous_totals,omitempty"`
+}
+
+func fetchBallotResponses(client *http.Client, ballotID string) ballotResponses {
+	b := ballotResponses{
+		QuestionIDs:  make(map[string][2]string),
+		Responses:    []*forms.FormResponse{},
+		LocalBallots: make(map[string]*localBallot),
+	}
+	if ballotID == localBallotID {
+		b.QuestionIDs, b.Responses, b.LocalBallots = loadLocalResponses()
+		if usingAnonymousBallot() {
+			b.AnonymousTotals = readAnonymousTotals()
+		}
+		return b
+	}
+
+	service, err := forms.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		panic(err)
+	}
+
+	ballot, err := service.Forms.Get(ballotID).Do()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, item := range ballot.Items {
+		if item.QuestionGroupItem != nil && item.QuestionGroupItem.Grid != nil && len(item.QuestionGroupItem.Questions) != 0 {
+			for _, row := range item.QuestionGroupItem.Questions {
+				b.QuestionIDs[row.QuestionId] = [2]string{item.Title, row.RowQuestion.Title}
+			}
+		}
+	}
+
+	formResponses, err := service.Forms.Responses.List(ballotID).Do()
+	if err != nil {
+		panic(err)
+	}
+	b.Responses = formResponses.Responses
+	return b
+}
+
+type voteCount struct {
+	// position => candidate => score
+	totalScores      map[string]map[string]float64
+	unweightedScores map[string]map[string]uint
+	ineligibleVoters []string
+	// {email, submission time} tuple
+	lateVoters      [][2]string
+	duplicateVoters []duplicate
+	votedMembers    map[*Member]bool
+	// position => number of ballots that voted for it
+	positionVotes map[string]uint
+	bulletin      []bulletinBallot
+	// {email, receipt} tuples for voters that haven't been given their receipt yet
+	receipts             [][2]string
+	numberEligibleVoters uint
+}
+
+// countVotes tallies the ballots. With offerAliases, the officer is asked who unknown addresses belong to; otherwise
+// they're ineligible unless already in config/aliases.json.
+func countVotes(b ballotResponses, offerAliases bool) voteCount {
+	c := voteCount{
+		totalScores:      make(map[string]map[string]float64),
+		unweightedScores: make(map[string]map[string]uint),
+		ineligibleVoters: []string{},
+		lateVoters:       [][2]string{},
+		votedMembers:     make(map[*Member]bool),
+		positionVotes:    make(map[string]uint),
+		bulletin:         []bulletinBallot{},
+		receipts:         [][2]string{},
+	}
+
+	counted := []*forms.FormResponse{}
+	// anonymous ballots were checked when their credential was issued, and can't be traced back to a member
+	anonymous := []*forms.FormResponse{}
+	for _, resp := range b.Responses {
+		if local, ok := b.LocalBallots[resp.ResponseId]; ok && local.Email == "" {
+			anonymous = append(anonymous, resp)
+			continue
+		}
+		if offerAliases && findMember(resp.RespondentEmail) == nil {
+			offerAlias(resp.RespondentEmail)
+		}
+		if !isEligibleVoter(resp.RespondentEmail) {
+			c.ineligibleVoters = append(c.ineligibleVoters, strings.ToLower(resp.RespondentEmail))
+			continue
+		}
+		if submittedLate(resp.LastSubmittedTime, voteDeadline) {
+			c.lateVoters = append(c.lateVoters, [2]string{strings.ToLower(resp.RespondentEmail), resp.LastSubmittedTime})
+			continue
+		}
+		counted = append(counted, resp)
+	}
+
+	counted, c.duplicateVoters = dedupeResponses(counted)
+	if len(anonymous) != 0 {
+		c.votedMembers = readRoll()
+	}
+	for _, resp := range append(counted, anonymous...) {
+		c.numberEligibleVoters += 1
+		member := findMember(resp.RespondentEmail)
+		weight := member.weight()
+		isAnonymous := false
+		// ballot server ballots come with the nonce their receipt was made with
+		nonce := ""
+		if local, ok := b.LocalBallots[resp.ResponseId]; ok {
+			nonce = local.Nonce
+			if local.Email == "" {
+				isAnonymous = true
+				weight = 1
+			}
+		}
+		// anonymous ballots' weighted scores and position votes come from their running totals instead
+		inTotals := isAnonymous && b.AnonymousTotals != nil
+		if !isAnonymous {
+			c.votedMembers[member] = true
+		}
+		ballotScores := make(map[string]map[string]uint)
+
+		for questionID, answer := range resp.Answers {
+			tuple := b.QuestionIDs[questionID]
+			position := tuple[0]
+			candidate := tuple[1]
+			// anonymous ballots only list the positions the voter may vote for
+			if !isAnonymous && !canVoteFor(findPosition(position), member) {
+				continue
+			}
+			score, err := strconv.ParseUint(answer.TextAnswers.Answers[0].Value, 10, 32)
+			if err != nil {
+				panic(err)
+			}
+			if c.totalScores[position] == nil {
+				c.totalScores[position] = make(map[string]float64)
+				c.unweightedScores[position] = make(map[string]uint)
+			}
+			if !inTotals {
+				c.totalScores[position][candidate] += weight * float64(score)
+			}
+			c.unweightedScores[position][candidate] += uint(score)
+			if ballotScores[position] == nil {
+				ballotScores[position] = make(map[string]uint)
+			}
+			ballotScores[position][candidate] = uint(score)
+		}
+
+		if !inTotals {
+			for position := range ballotScores {
+				c.positionVotes[position] += 1
+			}
+		}
+
+		if ballotsIdentifiable() {
+			continue
+		}
+		if nonce == "" {
+			nonce = randomToken()
+			if !isAnonymous {
+				c.receipts = append(c.receipts, [2]string{member.Email, ballotReceipt(nonce, weight, ballotScores)})
+			}
+		}
+		c.bulletin = append(c.bulletin, newBulletinBallot(nonce, weight, ballotScores))
+	}
+
+	if b.AnonymousTotals != nil && len(anonymous) != 0 {
+		for position, candidates := range b.AnonymousTotals.Scores {
+			if c.totalScores[position] == nil {
+				c.totalScores[position] = make(map[string]float64)
+				c.unweightedScores[position] = make(map[string]uint)
+			}
+			for candidate, score := range candidates {
+				c.totalScores[position][candidate] += score
+			}
+		}
+		for position, votes := range b.AnonymousTotals.PositionVotes {
+			c.positionVotes[position] += votes
+		}
+	}
+
+	sortBulletin(c.bulletin)
+	return c
+}
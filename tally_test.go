@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/forms/v1"
+)
+
+// useConfig replaces the election config for the rest of the test.
+func useConfig(t *testing.T, config Config) {
+	t.Helper()
+	electionConfig = config
+	t.Cleanup(func() { electionConfig = Config{} })
+}
+
+// ballotResponse is a response to the ballot form, scoring question IDs.
+func ballotResponse(id string, email string, submitted string, scores map[string]string) *forms.FormResponse {
+	answers := make(map[string]forms.Answer)
+	for questionID, score := range scores {
+		answers[questionID] = forms.Answer{TextAnswers: &forms.TextAnswers{Answers: []*forms.TextAnswer{{Value: score}}}}
+	}
+	return &forms.FormResponse{ResponseId: id, RespondentEmail: email, LastSubmittedTime: submitted, Answers: answers}
+}
+
+func TestCountVotes(t *testing.T) {
+	half := 0.5
+	tests := []struct {
+		name       string
+		weight     *float64
+		electorate []string
+		total      map[string]map[string]float64
+		unweighted map[string]map[string]uint
+		votes      map[string]uint
+		bulletin   int
+	}{{
+		name:       "unweighted",
+		total:      map[string]map[string]float64{"P": {"Ann": 2, "Bob": 3}, "VP": {"Ann": 2, "Bob": 2}},
+		unweighted: map[string]map[string]uint{"P": {"Ann": 2, "Bob": 3}, "VP": {"Ann": 2, "Bob": 2}},
+		votes:      map[string]uint{"P": 2, "VP": 2},
+		bulletin:   2,
+	}, {
+		name:       "weighted",
+		weight:     &half,
+		total:      map[string]map[string]float64{"P": {"Ann": 1, "Bob": 2.5}, "VP": {"Ann": 1, "Bob": 2}},
+		unweighted: map[string]map[string]uint{"P": {"Ann": 2, "Bob": 3}, "VP": {"Ann": 2, "Bob": 2}},
+		votes:      map[string]uint{"P": 2, "VP": 2},
+	}, {
+		name:       "electorate",
+		electorate: []string{"build"},
+		total:      map[string]map[string]float64{"P": {"Ann": 2, "Bob": 3}, "VP": {"Ann": 2}},
+		unweighted: map[string]map[string]uint{"P": {"Ann": 2, "Bob": 3}, "VP": {"Ann": 2}},
+		votes:      map[string]uint{"P": 2, "VP": 1},
+	}}
+
+	for _, test := range tests {
+		useConfig(t, Config{Positions: []Position{{Name: "P"}, {Name: "VP", Electorate: test.electorate}}})
+		useRoster(t,
+			&Member{Email: "a@example.org", Vote: true, Groups: []string{"build"}, Weight: test.weight},
+			&Member{Email: "b@example.org", Vote: true, Groups: []string{"programming"}},
+			&Member{Email: "c@example.org"},
+			&Member{Email: "d@example.org", Vote: true},
+		)
+		voteDeadline = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+		c := countVotes(ballotResponses{
+			QuestionIDs: map[string][2]string{"q1": {"P", "Ann"}, "q2": {"P", "Bob"}, "q3": {"VP", "Ann"}, "q4": {"VP", "Bob"}},
+			Responses: []*forms.FormResponse{
+				ballotResponse("1", "a@example.org", "2024-01-01T00:00:00Z", map[string]string{"q1": "2", "q2": "1", "q3": "2"}),
+				ballotResponse("2", "B@example.org", "2024-01-01T00:00:00Z", map[string]string{"q2": "2", "q4": "2"}),
+				ballotResponse("3", "c@example.org", "2024-01-01T00:00:00Z", map[string]string{"q1": "2"}),
+				ballotResponse("4", "d@example.org", "2024-01-03T00:00:00Z", map[string]string{"q1": "2"}),
+			},
+		}, false)
+
+		if !reflect.DeepEqual(c.totalScores, test.total) {
+			t.Errorf("%s: total scores %v, want %v", test.name, c.totalScores, test.total)
+		}
+		if !reflect.DeepEqual(c.unweightedScores, test.unweighted) {
+			t.Errorf("%s: unweighted scores %v, want %v", test.name, c.unweightedScores, test.unweighted)
+		}
+		if !reflect.DeepEqual(c.positionVotes, test.votes) {
+			t.Errorf("%s: position votes %v, want %v", test.name, c.positionVotes, test.votes)
+		}
+		if !reflect.DeepEqual(c.ineligibleVoters, []string{"c@example.org"}) {
+			t.Errorf("%s: ineligible voters %v", test.name, c.ineligibleVoters)
+		}
+		if len(c.lateVoters) != 1 || c.lateVoters[0][0] != "d@example.org" {
+			t.Errorf("%s: late voters %v", test.name, c.lateVoters)
+		}
+		if c.numberEligibleVoters != 2 {
+			t.Errorf("%s: %d eligible voters, want 2", test.name, c.numberEligibleVoters)
+		}
+		if len(c.bulletin) != test.bulletin || len(c.receipts) != test.bulletin {
+			t.Errorf("%s: %d ballots and %d receipts on the bulletin, want %d", test.name, len(c.bulletin), len(c.receipts), test.bulletin)
+		}
+	}
+	voteDeadline = time.Time{}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/api/forms/v1"
+	"google.golang.org/api/option"
+)
+
+type turnout struct {
+	// eligible voters that have voted
+	voted      map[*Member]bool
+	notVoted   []string
+	eligible   int
+	ineligible int
+}
+
+// countTurnout looks at who has responded to the ballot form. It only reads respondent emails and
+// submission times, never answers, so it is safe to use while voting is in progress.
+func countTurnout(service *forms.Service, ballotID string) turnout {
+	t := turnout{voted: make(map[*Member]bool)}
+
+	responses := []*forms.FormResponse{}
+	if ballotID == localBallotID && usingAnonymousBallot() {
+		// ballots can't be matched to voters, so this counts the voters that have opened their ballot
+		t.voted = readRoll()
+	} else if ballotID == localBallotID {
+		_, responses, _ = loadLocalResponses()
+	} else {
+		formResponses, err := service.Forms.Responses.List(ballotID).Do()
+		if err != nil {
+			panic(err)
+		}
+		responses = formResponses.Responses
+	}
+	for _, resp := range responses {
+		if !isEligibleVoter(resp.RespondentEmail) {
+			t.ineligible += 1
+		} else if !submittedLate(resp.LastSubmittedTime, voteDeadline) {
+			t.voted[findMember(resp.RespondentEmail)] = true
+		}
+	}
+
+	for _, email := range eligibleVoters {
+		if email == "" {
+			continue
+		}
+		t.eligible += 1
+		if !t.voted[findMember(email)] {
+			t.notVoted = append(t.notVoted, email)
+		}
+	}
+
+	return t
+}
+
+func handleTurnout(client *http.Client, dmOfficers bool) {
+	var ballotID string
+	{
+		ballotIDBytes, err := os.ReadFile("state/ballot.txt")
+		if err != nil {
+			fmt.Println("`state/ballot.txt' does not exist, meaning you haven't started the vote! Use the `start-vote' command to open the ballot.")
+			os.Exit(1)
+		}
+		ballotID = string(ballotIDBytes)
+	}
+
+	// the ballot server's ballots are read locally, so client is nil for them
+	var service *forms.Service
+	if ballotID != localBallotID {
+		var err error
+		service, err = forms.NewService(context.Background(), option.WithHTTPClient(client))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	t := countTurnout(service, ballotID)
+
+	fmt.Println(fmt.Sprint(len(t.voted)) + " of " + fmt.Sprint(t.eligible) + " eligible voters have voted.")
+	if t.ineligible != 0 {
+		fmt.Println(fmt.Sprint(t.ineligible) + " ineligible respondents have also submitted the ballot form.")
+	}
+
+	message := fmt.Sprint(len(t.voted)) + " of " + fmt.Sprint(t.eligible) + " members have voted in the " + electionConfig.Name + " so far. If you haven't voted yet, make sure to do so before the deadline!"
+	if hasElectorates() {
+		fmt.Println("Turnout by position:")
+		message += "\n\nTurnout by position:"
+		for _, line := range positionTurnout(t.voted) {
+			fmt.Println("\t- " + line)
+			message += "\n - " + line
+		}
+	}
+	sendWebhook(message)
+
+	if dmOfficers {
+		if len(discordConfig.OfficerIDs) == 0 {
+			fmt.Println("`officer_ids' is not set in `config/discord.json', so there is nobody to send the list of remaining voters to.")
+			os.Exit(1)
+		}
+		message = "Eligible voters that haven't voted in the " + electionConfig.Name + " yet:\n```\n" + strings.Join(t.notVoted, "\n") + "\n```"
+		if len(t.notVoted) == 0 {
+			message = "Every eligible voter has voted in the " + electionConfig.Name + "!"
+		}
+		for _, officerID := range discordConfig.OfficerIDs {
+			err := sendDirectMessage(officerID, message)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	fmt.Println("You're all set!")
+	os.Exit(0)
+}